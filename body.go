@@ -0,0 +1,49 @@
+package httx
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// rawBodyKey is the key the buffered body is stored under with Set
+const rawBodyKey = "httx.rawBody"
+
+// BufferBody returns middleware reading the whole request body up front, so
+// that middleware can inspect it, e.g. to verify a signature, without
+// consuming it for the handler. The bytes are available with RawBody, and
+// r.Body as well as r.GetBody read them from the start.
+//
+// Bodies longer than maxBytes are responded with 413 Content Too Large.
+func BufferBody(maxBytes int64) func(HandlerFunc) HandlerFunc {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) error {
+			b, err := io.ReadAll(io.LimitReader(r.Body, maxBytes+1))
+			if err != nil {
+				return &HTTPError{Code: http.StatusBadRequest, Err: fmt.Errorf("reading body: %w", err)}
+			}
+			if int64(len(b)) > maxBytes {
+				return &HTTPError{Code: http.StatusRequestEntityTooLarge}
+			}
+			_ = r.Body.Close()
+
+			r.Body = io.NopCloser(bytes.NewReader(b))
+			r.GetBody = func() (io.ReadCloser, error) {
+				return io.NopCloser(bytes.NewReader(b)), nil
+			}
+			r.ContentLength = int64(len(b))
+			Set(w, rawBodyKey, b)
+
+			return next(w, r)
+		}
+	}
+}
+
+// RawBody returns the body buffered by the BufferBody middleware, or nil.
+// It must not be modified.
+func RawBody(w http.ResponseWriter) []byte {
+	b, _ := Get(w, rawBodyKey)
+	raw, _ := b.([]byte)
+	return raw
+}
@@ -0,0 +1,82 @@
+package httx
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestBufferBody(t *testing.T) {
+	key := []byte("secret")
+	sign := func(body string) string {
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(body))
+		return hex.EncodeToString(mac.Sum(nil))
+	}
+
+	verify := func(next HandlerFunc) HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) error {
+			body, err := r.GetBody()
+			if err != nil {
+				return err
+			}
+			b, err := io.ReadAll(body)
+			if err != nil {
+				return err
+			}
+
+			if string(b) != string(RawBody(w)) {
+				t.Errorf("GetBody read %q, RawBody == %q", b, RawBody(w))
+			}
+			if !hmac.Equal([]byte(r.Header.Get("X-Signature")), []byte(sign(string(b)))) {
+				return Abort(http.StatusUnauthorized, "")
+			}
+			return next(w, r)
+		}
+	}
+
+	r := NewMux()
+	r.Pre(verify)
+	r.Pre(BufferBody(16))
+	r.POST("/hook", func(w http.ResponseWriter, r *http.Request) error {
+		b, err := io.ReadAll(r.Body)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(b)
+		return err
+	})
+
+	tests := []struct {
+		body, signature string
+		code            int
+	}{
+		{`{"event":"push"}`, sign(`{"event":"push"}`), http.StatusOK},
+		{`{"event":"push"}`, sign("forged"), http.StatusUnauthorized},
+		{`{"event":"push!!"}`, sign(`{"event":"push!!"}`), http.StatusRequestEntityTooLarge},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/hook", strings.NewReader(test.body))
+		req.Header.Set("X-Signature", test.signature)
+
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+
+		if rec.Code != test.code {
+			t.Errorf("POST /hook with %q: got %d, want %d", test.body, rec.Code, test.code)
+		}
+		if test.code == http.StatusOK && rec.Body.String() != test.body {
+			t.Errorf("POST /hook with %q: handler read %q", test.body, rec.Body.String())
+		}
+	}
+
+	if b := RawBody(httptest.NewRecorder()); b != nil {
+		t.Errorf("RawBody without the middleware == %q, want nil", b)
+	}
+}
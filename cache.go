@@ -0,0 +1,67 @@
+package httx
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// CacheControl returns middleware setting "Cache-Control: public, max-age=N"
+// on successful responses of GET and HEAD requests, as well as on 304 Not
+// Modified ones. A Cache-Control header set by the handler is kept, and
+// responses written by OnError are not affected.
+func CacheControl(maxAge time.Duration) func(HandlerFunc) HandlerFunc {
+	value := "public, max-age=" + strconv.FormatInt(int64(maxAge/time.Second), 10)
+
+	return func(next HandlerFunc) HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) error {
+			if r.Method != http.MethodGet && r.Method != http.MethodHead {
+				return next(w, r)
+			}
+
+			return next(&cacheWriter{ResponseWriter: w, value: value}, r)
+		}
+	}
+}
+
+// cacheWriter sets the Cache-Control header once the status is known
+type cacheWriter struct {
+	http.ResponseWriter
+	value       string
+	wroteHeader bool
+}
+
+func (cw *cacheWriter) WriteHeader(code int) {
+	if !cw.wroteHeader && code >= 200 {
+		cw.wroteHeader = true
+
+		h := cw.Header()
+		if (code < 300 || code == http.StatusNotModified) && h.Get("Cache-Control") == "" {
+			h.Set("Cache-Control", cw.value)
+		}
+	}
+	cw.ResponseWriter.WriteHeader(code)
+}
+
+func (cw *cacheWriter) Write(b []byte) (int, error) {
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+	return cw.ResponseWriter.Write(b)
+}
+
+func (cw *cacheWriter) Flush() {
+	_ = cw.FlushError()
+}
+
+// FlushError sends the headers first, as used by http.ResponseController.
+func (cw *cacheWriter) FlushError() error {
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+	return http.NewResponseController(cw.ResponseWriter).Flush()
+}
+
+func (cw *cacheWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
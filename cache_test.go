@@ -0,0 +1,50 @@
+package httx
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestCacheControl(t *testing.T) {
+	r := NewMux()
+	r.Pre(CacheControl(time.Hour))
+
+	ok := func(w http.ResponseWriter, r *http.Request) error {
+		_, err := w.Write([]byte("ok"))
+		return err
+	}
+	r.GET("/public", ok)
+	r.HEAD("/public", ok)
+	r.POST("/public", ok)
+	r.GET("/private", func(w http.ResponseWriter, r *http.Request) error {
+		w.Header().Set("Cache-Control", "no-store")
+		return nil
+	})
+	r.GET("/missing", func(w http.ResponseWriter, r *http.Request) error {
+		w.WriteHeader(http.StatusNotFound)
+		return nil
+	})
+	r.GET("/error", func(w http.ResponseWriter, r *http.Request) error {
+		return Abort(http.StatusForbidden, "")
+	})
+
+	tests := []struct {
+		method, path string
+		want         string
+	}{
+		{http.MethodGet, "/public", "public, max-age=3600"},
+		{http.MethodHead, "/public", "public, max-age=3600"},
+		{http.MethodPost, "/public", ""},
+		{http.MethodGet, "/private", "no-store"},
+		{http.MethodGet, "/missing", ""},
+		{http.MethodGet, "/error", ""},
+	}
+
+	for _, test := range tests {
+		rec := r.TestRequest(test.method, test.path, nil)
+		if got := rec.Header().Get("Cache-Control"); got != test.want {
+			t.Errorf("%s %s: Cache-Control == %q, want %q", test.method, test.path, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package httx
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// SecureCookies returns middleware hardening the cookies set by handlers,
+// adding the HttpOnly and SameSite=Lax attributes to Set-Cookie headers
+// lacking them, as well as Secure for requests over TLS. Attributes already
+// present are kept as is, e.g. SameSite=Strict.
+//
+// The cookies named in scriptReadable don't get HttpOnly, as scripts must be
+// able to read them, e.g. the one of the CSRF middleware:
+//
+//	mux.Pre(httx.CSRF(httx.CSRFOptions{}))
+//	mux.Pre(httx.SecureCookies("csrf_token"))
+//
+// Cookies set before returning an error are hardened for the response
+// written by OnError as well.
+func SecureCookies(scriptReadable ...string) func(HandlerFunc) HandlerFunc {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) error {
+			cw := &cookieWriter{ResponseWriter: w, secure: r.TLS != nil, scriptReadable: scriptReadable}
+			err := next(cw, r)
+			if !cw.wroteHeader {
+				cw.rewrite()
+			}
+			return err
+		}
+	}
+}
+
+// cookieWriter rewrites the Set-Cookie headers once the header is written
+type cookieWriter struct {
+	http.ResponseWriter
+	secure         bool
+	scriptReadable []string
+	wroteHeader    bool
+}
+
+// rewrite hardens the Set-Cookie headers set so far
+func (cw *cookieWriter) rewrite() {
+	cookies := cw.Header()["Set-Cookie"]
+	for i, cookie := range cookies {
+		cookies[i] = secureCookie(cookie, cw.secure, cw.scriptReadable)
+	}
+}
+
+func (cw *cookieWriter) WriteHeader(code int) {
+	if !cw.wroteHeader {
+		cw.rewrite()
+
+		// informational responses may precede the final one
+		cw.wroteHeader = code >= 200
+	}
+	cw.ResponseWriter.WriteHeader(code)
+}
+
+func (cw *cookieWriter) Write(b []byte) (int, error) {
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+	return cw.ResponseWriter.Write(b)
+}
+
+func (cw *cookieWriter) Flush() {
+	_ = cw.FlushError()
+}
+
+// FlushError sends the headers first, as used by http.ResponseController.
+func (cw *cookieWriter) FlushError() error {
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+	return http.NewResponseController(cw.ResponseWriter).Flush()
+}
+
+func (cw *cookieWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+// secureCookie appends the attributes missing from the Set-Cookie value,
+// leaving out HttpOnly for the script readable cookies
+func secureCookie(cookie string, secure bool, scriptReadable []string) string {
+	var hasSecure, hasHttpOnly, hasSameSite bool
+
+	// the first part is the name and value of the cookie
+	pair, attrs, _ := strings.Cut(cookie, ";")
+	name, _, _ := strings.Cut(pair, "=")
+	hasHttpOnly = slices.Contains(scriptReadable, strings.TrimSpace(name))
+
+	for _, attr := range strings.Split(attrs, ";") {
+		name, _, _ := strings.Cut(attr, "=")
+		switch name = strings.TrimSpace(name); {
+		case strings.EqualFold(name, "Secure"):
+			hasSecure = true
+		case strings.EqualFold(name, "HttpOnly"):
+			hasHttpOnly = true
+		case strings.EqualFold(name, "SameSite"):
+			hasSameSite = true
+		}
+	}
+
+	if secure && !hasSecure {
+		cookie += "; Secure"
+	}
+	if !hasHttpOnly {
+		cookie += "; HttpOnly"
+	}
+	if !hasSameSite {
+		cookie += "; SameSite=Lax"
+	}
+
+	return cookie
+}
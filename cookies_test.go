@@ -0,0 +1,92 @@
+package httx
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestSecureCookies(t *testing.T) {
+	r := NewMux()
+	r.Pre(SecureCookies())
+	r.GET("/login", func(w http.ResponseWriter, r *http.Request) error {
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "abc"})
+		http.SetCookie(w, &http.Cookie{Name: "prefs", Value: "dark", Path: "/", HttpOnly: true, Secure: true, SameSite: http.SameSiteStrictMode})
+		w.Header().Add("Set-Cookie", "legacy=1; secure; httponly; samesite=none")
+		_, err := w.Write([]byte("ok"))
+		return err
+	})
+	r.GET("/error", func(w http.ResponseWriter, r *http.Request) error {
+		http.SetCookie(w, &http.Cookie{Name: "attempt", Value: "1"})
+		return Abort(http.StatusUnauthorized, "")
+	})
+
+	tests := []struct {
+		path string
+		tls  bool
+		want []string
+	}{
+		{"/login", false, []string{
+			"session=abc; HttpOnly; SameSite=Lax",
+			"prefs=dark; Path=/; HttpOnly; Secure; SameSite=Strict",
+			"legacy=1; secure; httponly; samesite=none",
+		}},
+		{"/login", true, []string{
+			"session=abc; Secure; HttpOnly; SameSite=Lax",
+			"prefs=dark; Path=/; HttpOnly; Secure; SameSite=Strict",
+			"legacy=1; secure; httponly; samesite=none",
+		}},
+		{"/error", false, []string{"attempt=1; HttpOnly; SameSite=Lax"}},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest(http.MethodGet, test.path, nil)
+		if test.tls {
+			req.TLS = &tls.ConnectionState{}
+		}
+
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		if got := rec.Header().Values("Set-Cookie"); !slices.Equal(got, test.want) {
+			t.Errorf("GET %s over TLS %t: Set-Cookie == %q, want %q", test.path, test.tls, got, test.want)
+		}
+	}
+}
+
+func TestSecureCookiesCSRF(t *testing.T) {
+	for _, test := range []struct {
+		scriptReadable []string
+		httpOnly       bool
+	}{
+		{nil, true},
+		{[]string{"csrf_token"}, false},
+	} {
+		r := NewMux()
+		r.Pre(CSRF(CSRFOptions{}))
+		r.Pre(SecureCookies(test.scriptReadable...))
+		r.GET("/form", func(w http.ResponseWriter, r *http.Request) error {
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "abc"})
+			return nil
+		})
+
+		rec := r.TestRequest(http.MethodGet, "/form", nil)
+		cookies := rec.Header().Values("Set-Cookie")
+		if len(cookies) != 2 {
+			t.Fatalf("script readable %q: Set-Cookie == %q, want 2 cookies", test.scriptReadable, cookies)
+		}
+
+		for _, cookie := range cookies {
+			switch {
+			case strings.HasPrefix(cookie, "session="):
+				if cookie != "session=abc; HttpOnly; SameSite=Lax" {
+					t.Errorf("script readable %q: session cookie == %q", test.scriptReadable, cookie)
+				}
+			case strings.Contains(cookie, "HttpOnly") != test.httpOnly || !strings.Contains(cookie, "SameSite=Lax"):
+				t.Errorf("script readable %q: CSRF cookie == %q, want HttpOnly %t", test.scriptReadable, cookie, test.httpOnly)
+			}
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package httx
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORSOptions configures the answers to CORS preflight requests, see
+// Mux.CORS.
+type CORSOptions struct {
+	// Origins allowed to make requests, "*" allowing any.
+	AllowOrigins []string
+
+	// Headers allowed in requests. If empty, the headers requested by the
+	// preflight are allowed.
+	AllowHeaders []string
+
+	// Whether requests may carry credentials. The origin is echoed instead
+	// of "*" then, as browsers reject the wildcard with credentials.
+	AllowCredentials bool
+
+	// How long the preflight response may be cached, unset if zero.
+	MaxAge time.Duration
+}
+
+// preflight writes the Access-Control-Allow-* headers for a preflight request
+// from an allowed origin, allowing the methods in allow.
+func (o *CORSOptions) preflight(w http.ResponseWriter, r *http.Request, allow []string) {
+	origin := r.Header.Get("Origin")
+	if origin == "" || r.Header.Get("Access-Control-Request-Method") == "" {
+		return
+	}
+
+	Vary(w, "Origin")
+	h := w.Header()
+
+	anyOrigin := slices.Contains(o.AllowOrigins, "*")
+	switch {
+	case anyOrigin && !o.AllowCredentials:
+		h.Set("Access-Control-Allow-Origin", "*")
+	case anyOrigin || slices.Contains(o.AllowOrigins, origin):
+		h.Set("Access-Control-Allow-Origin", origin)
+	default:
+		return
+	}
+
+	h.Set("Access-Control-Allow-Methods", strings.Join(allow, ", "))
+
+	if len(o.AllowHeaders) > 0 {
+		h.Set("Access-Control-Allow-Headers", strings.Join(o.AllowHeaders, ", "))
+	} else if requested := r.Header.Get("Access-Control-Request-Headers"); requested != "" {
+		h.Set("Access-Control-Allow-Headers", requested)
+	}
+
+	if o.AllowCredentials {
+		h.Set("Access-Control-Allow-Credentials", "true")
+	}
+
+	if o.MaxAge > 0 {
+		h.Set("Access-Control-Max-Age", strconv.Itoa(int(o.MaxAge.Seconds())))
+	}
+}
@@ -0,0 +1,65 @@
+package httx
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCORSPreflight(t *testing.T) {
+	noop := func(http.ResponseWriter, *http.Request) error { return nil }
+
+	r := NewMux()
+	r.CORS = &CORSOptions{
+		AllowOrigins: []string{"https://example.com"},
+		MaxAge:       10 * time.Minute,
+	}
+	r.GET("/users", noop)
+	r.POST("/users", noop)
+	r.DELETE("/users/{id}", noop)
+
+	preflight := func(path, origin string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodOptions, path, nil)
+		req.Header.Set("Origin", origin)
+		req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+		req.Header.Set("Access-Control-Request-Headers", "Content-Type")
+
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+
+	tests := []struct {
+		path, origin         string
+		allow, allowMethods  string
+		allowOrigin, headers string
+	}{
+		{"/users", "https://example.com", "GET, OPTIONS, POST", "GET, OPTIONS, POST", "https://example.com", "Content-Type"},
+		{"/users/1", "https://example.com", "DELETE, OPTIONS", "DELETE, OPTIONS", "https://example.com", "Content-Type"},
+		{"/users", "https://evil.com", "GET, OPTIONS, POST", "", "", ""},
+	}
+
+	for _, test := range tests {
+		rec := preflight(test.path, test.origin)
+		h := rec.Header()
+
+		if got := h.Values("Allow"); strings.Join(got, ", ") != test.allow {
+			t.Errorf("OPTIONS %s from %s: Allow == %q, want %q", test.path, test.origin, got, test.allow)
+		}
+		if got := h.Get("Access-Control-Allow-Methods"); got != test.allowMethods {
+			t.Errorf("OPTIONS %s from %s: Access-Control-Allow-Methods == %q, want %q", test.path, test.origin, got, test.allowMethods)
+		}
+		if got := h.Get("Access-Control-Allow-Origin"); got != test.allowOrigin {
+			t.Errorf("OPTIONS %s from %s: Access-Control-Allow-Origin == %q, want %q", test.path, test.origin, got, test.allowOrigin)
+		}
+		if got := h.Get("Access-Control-Allow-Headers"); got != test.headers {
+			t.Errorf("OPTIONS %s from %s: Access-Control-Allow-Headers == %q, want %q", test.path, test.origin, got, test.headers)
+		}
+	}
+
+	if got := preflight("/users", "https://example.com").Header().Get("Access-Control-Max-Age"); got != "600" {
+		t.Errorf("Access-Control-Max-Age == %q, want %q", got, "600")
+	}
+}
@@ -0,0 +1,100 @@
+package httx
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"net/http"
+	"time"
+)
+
+// CSRFOptions configures the CSRF middleware, zero values meaning the
+// defaults.
+type CSRFOptions struct {
+	// Name of the cookie holding the token, "csrf_token" by default.
+	CookieName string
+
+	// Name of the header carrying the token in unsafe requests,
+	// "X-CSRF-Token" by default.
+	HeaderName string
+
+	// Name of the form field carrying the token if the header is missing,
+	// "csrf_token" by default.
+	FieldName string
+
+	// How long the cookie lasts, 12 hours by default.
+	TTL time.Duration
+}
+
+type csrfKey struct{}
+
+// CSRF returns middleware protecting from cross-site request forgery with
+// the double-submit cookie pattern. Safe requests, i.e. GET, HEAD, OPTIONS
+// and TRACE, get a cookie with a random token if they lack one. Any other
+// request must carry the token of its cookie in the header or form field,
+// otherwise it's rejected with a 403 *HTTPError.
+//
+// The cookie is readable by scripts, so that they can copy it into the
+// header. Handlers rendering forms get the token with CSRFToken.
+func CSRF(opts CSRFOptions) func(HandlerFunc) HandlerFunc {
+	if opts.CookieName == "" {
+		opts.CookieName = "csrf_token"
+	}
+	if opts.HeaderName == "" {
+		opts.HeaderName = "X-CSRF-Token"
+	}
+	if opts.FieldName == "" {
+		opts.FieldName = "csrf_token"
+	}
+	if opts.TTL == 0 {
+		opts.TTL = 12 * time.Hour
+	}
+
+	return func(next HandlerFunc) HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) error {
+			var token string
+			if cookie, err := r.Cookie(opts.CookieName); err == nil {
+				token = cookie.Value
+			}
+
+			switch r.Method {
+			case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+				if token == "" {
+					b := make([]byte, 32)
+					if _, err := rand.Read(b); err != nil {
+						return err
+					}
+					token = base64.RawURLEncoding.EncodeToString(b)
+
+					http.SetCookie(w, &http.Cookie{
+						Name:     opts.CookieName,
+						Value:    token,
+						Path:     "/",
+						MaxAge:   int(opts.TTL.Seconds()),
+						Secure:   r.TLS != nil,
+						SameSite: http.SameSiteLaxMode,
+					})
+				}
+			default:
+				sent := r.Header.Get(opts.HeaderName)
+				if sent == "" {
+					sent = r.PostFormValue(opts.FieldName)
+				}
+
+				if token == "" || subtle.ConstantTimeCompare([]byte(sent), []byte(token)) != 1 {
+					return Abort(http.StatusForbidden, "invalid CSRF token")
+				}
+			}
+
+			return next(w, r.WithContext(context.WithValue(r.Context(), csrfKey{}, token)))
+		}
+	}
+}
+
+// CSRFToken returns the token of the request passed through the CSRF
+// middleware, or an empty string.
+func CSRFToken(r *http.Request) string {
+	token, _ := r.Context().Value(csrfKey{}).(string)
+	return token
+}
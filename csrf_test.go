@@ -0,0 +1,78 @@
+package httx
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestCSRF(t *testing.T) {
+	r := NewMux()
+	r.Pre(CSRF(CSRFOptions{}))
+	r.GET("/form", func(w http.ResponseWriter, r *http.Request) error {
+		_, err := w.Write([]byte(CSRFToken(r)))
+		return err
+	})
+	r.POST("/form", func(w http.ResponseWriter, r *http.Request) error {
+		_, err := w.Write([]byte("ok"))
+		return err
+	})
+
+	rec := r.TestRequest(http.MethodGet, "/form", nil)
+	cookies := rec.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != "csrf_token" || cookies[0].Value == "" {
+		t.Fatalf("GET /form: got cookies %v, want a csrf_token one", cookies)
+	}
+	token := cookies[0].Value
+	if rec.Body.String() != token {
+		t.Errorf("CSRFToken() == %q, want %q", rec.Body.String(), token)
+	}
+
+	// the existing cookie is kept
+	req := httptest.NewRequest(http.MethodGet, "/form", nil)
+	req.AddCookie(cookies[0])
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	if len(rec.Result().Cookies()) != 0 || rec.Body.String() != token {
+		t.Errorf("GET /form with a token: got cookies %v and body %q, want none and %q", rec.Result().Cookies(), rec.Body.String(), token)
+	}
+
+	tests := []struct {
+		name          string
+		cookie        string
+		header, field string
+		code          int
+	}{
+		{"valid header", token, token, "", http.StatusOK},
+		{"valid field", token, "", token, http.StatusOK},
+		{"missing token", token, "", "", http.StatusForbidden},
+		{"mismatched token", token, "forged", "", http.StatusForbidden},
+		{"mismatched field", token, "", "forged", http.StatusForbidden},
+		{"missing cookie", "", token, "", http.StatusForbidden},
+	}
+
+	for _, test := range tests {
+		form := url.Values{}
+		if test.field != "" {
+			form.Set("csrf_token", test.field)
+		}
+
+		req := httptest.NewRequest(http.MethodPost, "/form", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if test.cookie != "" {
+			req.AddCookie(&http.Cookie{Name: "csrf_token", Value: test.cookie})
+		}
+		if test.header != "" {
+			req.Header.Set("X-CSRF-Token", test.header)
+		}
+
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+
+		if rec.Code != test.code {
+			t.Errorf("%s: got %d, want %d", test.name, rec.Code, test.code)
+		}
+	}
+}
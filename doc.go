@@ -37,5 +37,13 @@ Grouping is also supported, but their ergonomics aren't traditional, instead you
 	})
 
 	_ = http.ListenAndServe(":8080", mux)
+
+# Path params
+
+A plain param, e.g. "{id}", matches a single segment, as does a regex param
+whose regex can't match a slash, e.g. `{id:\d+}`. A regex param ending the
+route, whose regex may match slashes, takes the rest of the path if its regex
+matches it whole, e.g. `{path:.*\.(jpg|png)}` matches "a/b/c.png" but not
+"a/b/c.gif". Unlike the catch-all "{path:*}", it doesn't match the empty rest.
 */
 package httx
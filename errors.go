@@ -0,0 +1,94 @@
+package httx
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// HTTPError is an error carrying the status code it should be responded
+// with. DefaultErrorHandler writes Code and the message of Err, or the
+// status text if Err is nil or Code is a 5xx one, see Mux.ExposeErrors.
+// Codes outside of 200-599, e.g. zero or informational ones, are responded
+// with 500 Internal Server Error.
+type HTTPError struct {
+	Code int
+	Err  error
+}
+
+func (e *HTTPError) Error() string {
+	if e.Err == nil {
+		return http.StatusText(e.Code)
+	}
+	return e.Err.Error()
+}
+
+func (e *HTTPError) Unwrap() error {
+	return e.Err
+}
+
+// Abort returns an *HTTPError with the code and message, or the status text
+// if msg is empty. Returning it from middleware stops the chain, leaving the
+// response to OnError:
+//
+//	func auth(next httx.HandlerFunc) httx.HandlerFunc {
+//		return func(w http.ResponseWriter, r *http.Request) error {
+//			if r.Header.Get("Authorization") == "" {
+//				return httx.Abort(http.StatusUnauthorized, "no credentials")
+//			}
+//			return next(w, r)
+//		}
+//	}
+func Abort(code int, msg string) error {
+	if msg == "" {
+		return &HTTPError{Code: code}
+	}
+	return &HTTPError{Code: code, Err: errors.New(msg)}
+}
+
+// StatusClientClosedRequest is the non-standard status code nginx uses for
+// requests the client went away from before getting a response
+const StatusClientClosedRequest = 499
+
+// SetRetryAfter sets the Retry-After header to d in seconds, rounded up, so
+// that clients of e.g. 429 Too Many Requests or 503 Service Unavailable
+// responses know when to try again.
+func SetRetryAfter(w http.ResponseWriter, d time.Duration) {
+	seconds := int64((d + time.Second - 1) / time.Second)
+	w.Header().Set("Retry-After", strconv.FormatInt(max(seconds, 0), 10))
+}
+
+// Cancelled reports whether the context of the request is done, i.e. the
+// client disconnected or the deadline exceeded. Handlers doing expensive work
+// should check it and return early, preferably with the context error.
+func Cancelled(r *http.Request) bool {
+	return r.Context().Err() != nil
+}
+
+// IsClientDisconnect reports whether err is caused by the client cancelling
+// the request.
+func IsClientDisconnect(err error) bool {
+	return errors.Is(err, context.Canceled)
+}
+
+// statusOf returns the code of an HTTPError within err, 499 for client
+// disconnects, 503 for exceeded deadlines, 500 otherwise, as well as for
+// HTTPErrors with codes outside of 200-599, e.g. a zero one
+func statusOf(err error) int {
+	var httpErr *HTTPError
+	switch {
+	case errors.As(err, &httpErr):
+		if httpErr.Code < 200 || httpErr.Code > 599 {
+			return http.StatusInternalServerError
+		}
+		return httpErr.Code
+	case IsClientDisconnect(err):
+		return StatusClientClosedRequest
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,150 @@
+package httx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAbort(t *testing.T) {
+	r := NewMux()
+	r.Pre(func(next HandlerFunc) HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) error {
+			if r.Header.Get("Authorization") == "" {
+				return Abort(http.StatusUnauthorized, "no")
+			}
+			return next(w, r)
+		}
+	})
+
+	reached := false
+	r.GET("/", func(w http.ResponseWriter, r *http.Request) error {
+		reached = true
+		return nil
+	})
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if reached {
+		t.Error("handler was reached despite the middleware aborting")
+	}
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("got %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+	if body := strings.TrimSpace(rec.Body.String()); body != "no" {
+		t.Errorf("got body %q, want %q", body, "no")
+	}
+
+	if err := Abort(http.StatusForbidden, ""); err.Error() != http.StatusText(http.StatusForbidden) {
+		t.Errorf("got message %q, want the status text", err.Error())
+	}
+}
+
+func TestClientDisconnect(t *testing.T) {
+	r := NewMux()
+	r.GET("/slow", func(w http.ResponseWriter, r *http.Request) error {
+		if Cancelled(r) {
+			return fmt.Errorf("slow: %w", r.Context().Err())
+		}
+		return nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/slow", nil).WithContext(ctx))
+
+	if rec.Code != StatusClientClosedRequest {
+		t.Errorf("got %d, want %d", rec.Code, StatusClientClosedRequest)
+	}
+
+	if !IsClientDisconnect(fmt.Errorf("wrapped: %w", context.Canceled)) {
+		t.Error("IsClientDisconnect(wrapped context.Canceled) == false, want true")
+	}
+	if IsClientDisconnect(context.DeadlineExceeded) {
+		t.Error("IsClientDisconnect(context.DeadlineExceeded) == true, want false")
+	}
+}
+
+func TestSetRetryAfter(t *testing.T) {
+	r := NewMux()
+	r.GET("/limited", func(w http.ResponseWriter, r *http.Request) error {
+		SetRetryAfter(w, 1500*time.Millisecond)
+		return Abort(http.StatusTooManyRequests, "")
+	})
+
+	rec := r.TestRequest(http.MethodGet, "/limited", nil)
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") != "2" {
+		t.Errorf("got %d with Retry-After %q, want %d with %q", rec.Code, rec.Header().Get("Retry-After"), http.StatusTooManyRequests, "2")
+	}
+
+	for d, want := range map[time.Duration]string{0: "0", time.Second: "1", time.Minute + 1: "61", -time.Second: "0"} {
+		rec := httptest.NewRecorder()
+		SetRetryAfter(rec, d)
+		if got := rec.Header().Get("Retry-After"); got != want {
+			t.Errorf("SetRetryAfter(%v): Retry-After == %q, want %q", d, got, want)
+		}
+	}
+}
+
+func TestExposeErrors(t *testing.T) {
+	r := NewMux()
+	r.GET("/internal", func(w http.ResponseWriter, r *http.Request) error {
+		return errors.New("dial tcp 10.0.0.1:5432: connection refused")
+	})
+	r.GET("/http", func(w http.ResponseWriter, r *http.Request) error {
+		return Abort(http.StatusBadRequest, "missing name")
+	})
+	r.GET("/file", func(w http.ResponseWriter, r *http.Request) error {
+		return fileError(errors.New("open /srv/data/secret: input/output error"))
+	})
+
+	tests := []struct {
+		expose     bool
+		path, body string
+	}{
+		{false, "/internal", "Internal Server Error"},
+		{false, "/http", "missing name"},
+		{false, "/file", "Internal Server Error"},
+		{true, "/internal", "dial tcp 10.0.0.1:5432: connection refused"},
+		{true, "/http", "missing name"},
+		{true, "/file", "open /srv/data/secret: input/output error"},
+	}
+
+	for _, test := range tests {
+		r.ExposeErrors = test.expose
+		rec := r.TestRequest(http.MethodGet, test.path, nil)
+		if body := strings.TrimSpace(rec.Body.String()); body != test.body {
+			t.Errorf("ExposeErrors %t, GET %s: got body %q, want %q", test.expose, test.path, body, test.body)
+		}
+	}
+}
+
+func TestHTTPErrorZeroCode(t *testing.T) {
+	r := NewMux()
+	r.GET("/zero", func(w http.ResponseWriter, r *http.Request) error {
+		return &HTTPError{Err: errors.New("no code")}
+	})
+	r.GET("/empty", func(w http.ResponseWriter, r *http.Request) error {
+		return &HTTPError{}
+	})
+
+	for _, path := range []string{"/zero", "/empty"} {
+		if rec := r.TestRequest(http.MethodGet, path, nil); rec.Code != http.StatusInternalServerError {
+			t.Errorf("GET %s: got %d, want %d", path, rec.Code, http.StatusInternalServerError)
+		}
+	}
+
+	for code, want := range map[int]int{0: 500, -1: 500, 99: 500, 100: 500, 199: 500, 600: 500, 999: 500, 1000: 500, 200: 200, 404: 404, 599: 599} {
+		if got := statusOf(&HTTPError{Code: code}); got != want {
+			t.Errorf("statusOf(&HTTPError{Code: %d}) == %d, want %d", code, got, want)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package httx
+
+import (
+	"bytes"
+	"encoding/hex"
+	"hash/fnv"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// DefaultETagMaxSize is the size of the largest response ETag buffers.
+const DefaultETagMaxSize = 1 << 20
+
+// ETag returns middleware adding an ETag to responses of GET and HEAD
+// requests up to DefaultETagMaxSize, see ETagMaxSize.
+func ETag() func(HandlerFunc) HandlerFunc {
+	return ETagMaxSize(DefaultETagMaxSize)
+}
+
+// ETagMaxSize returns middleware adding an ETag, a hash of the body, to
+// successful responses of GET and HEAD requests, answering with 304 Not
+// Modified if it matches the If-None-Match header of the request.
+//
+// The whole response is buffered in memory to be hashed, thus responses
+// growing larger than maxSize, or flushed by the handler, are written as is
+// without an ETag.
+func ETagMaxSize(maxSize int) func(HandlerFunc) HandlerFunc {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) error {
+			if r.Method != http.MethodGet && r.Method != http.MethodHead {
+				return next(w, r)
+			}
+
+			ew := &etagWriter{ResponseWriter: w, maxSize: maxSize}
+			if err := next(ew, r); err != nil {
+				ew.passthrough()
+				return err
+			}
+			if ew.passed {
+				return nil
+			}
+
+			if ew.code != 0 && ew.code != http.StatusOK {
+				ew.passthrough()
+				return nil
+			}
+
+			h := w.Header()
+			etag := h.Get("ETag")
+			if etag == "" {
+				sum := fnv.New64a()
+				sum.Write(ew.buf.Bytes())
+				etag = `"` + hex.EncodeToString(sum.Sum(nil)) + `"`
+				h.Set("ETag", etag)
+			}
+
+			if etagMatches(r.Header.Get("If-None-Match"), etag) {
+				h.Del("Content-Length")
+				w.WriteHeader(http.StatusNotModified)
+				return nil
+			}
+
+			ew.passthrough()
+			return nil
+		}
+	}
+}
+
+// NotModifiedSince sets the Last-Modified header, and ETag if etag is given,
+// then checks whether the client's cached copy is fresh according to the
+// If-None-Match or If-Modified-Since headers of the request. If so, it
+// responds with 304 Not Modified and returns true, so that the handler can
+// return early:
+//
+//	if httx.NotModifiedSince(w, r, post.UpdatedAt, post.Version) {
+//		return nil
+//	}
+//
+// If-None-Match takes precedence, as per RFC 9110. A zero modtime is ignored.
+func NotModifiedSince(w http.ResponseWriter, r *http.Request, modtime time.Time, etag ...string) bool {
+	h := w.Header()
+
+	tag := ""
+	if len(etag) > 0 && etag[0] != "" {
+		tag = etag[0]
+		if !strings.HasPrefix(tag, `"`) && !strings.HasPrefix(tag, `W/"`) {
+			tag = `"` + tag + `"`
+		}
+		h.Set("ETag", tag)
+	}
+
+	modtime = modtime.Truncate(time.Second)
+	if !modtime.IsZero() && !modtime.Equal(time.Unix(0, 0)) {
+		h.Set("Last-Modified", modtime.UTC().Format(http.TimeFormat))
+	}
+
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+
+	var fresh bool
+	if ifNoneMatch := r.Header.Get("If-None-Match"); ifNoneMatch != "" {
+		fresh = tag != "" && etagMatches(ifNoneMatch, tag)
+	} else if since, err := http.ParseTime(r.Header.Get("If-Modified-Since")); err == nil && !modtime.IsZero() {
+		fresh = !modtime.After(since)
+	}
+
+	if fresh {
+		h.Del("Content-Type")
+		h.Del("Content-Length")
+		w.WriteHeader(http.StatusNotModified)
+	}
+
+	return fresh
+}
+
+// etagMatches reports whether the If-None-Match header lists the etag, using
+// the weak comparison
+func etagMatches(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// etagWriter buffers the response until it's complete or exceeds maxSize
+type etagWriter struct {
+	http.ResponseWriter
+	maxSize int
+	code    int
+	buf     bytes.Buffer
+	passed  bool
+}
+
+func (ew *etagWriter) WriteHeader(code int) {
+	switch {
+	case ew.passed:
+		ew.ResponseWriter.WriteHeader(code)
+	case code < 200:
+		// informational responses don't end the response
+		ew.ResponseWriter.WriteHeader(code)
+	case ew.code == 0:
+		ew.code = code
+	}
+}
+
+func (ew *etagWriter) Write(b []byte) (int, error) {
+	if !ew.passed && ew.buf.Len()+len(b) > ew.maxSize {
+		ew.passthrough()
+	}
+	if ew.passed {
+		return ew.ResponseWriter.Write(b)
+	}
+	return ew.buf.Write(b)
+}
+
+func (ew *etagWriter) Flush() {
+	_ = ew.FlushError()
+}
+
+// FlushError gives up on buffering, as used by http.ResponseController.
+func (ew *etagWriter) FlushError() error {
+	ew.passthrough()
+	return http.NewResponseController(ew.ResponseWriter).Flush()
+}
+
+func (ew *etagWriter) Unwrap() http.ResponseWriter {
+	return ew.ResponseWriter
+}
+
+// passthrough writes what was buffered and stops buffering
+func (ew *etagWriter) passthrough() {
+	if ew.passed {
+		return
+	}
+	ew.passed = true
+
+	if ew.code != 0 {
+		ew.ResponseWriter.WriteHeader(ew.code)
+	}
+	if ew.buf.Len() > 0 {
+		_, _ = ew.ResponseWriter.Write(ew.buf.Bytes())
+	}
+	ew.buf = bytes.Buffer{}
+}
@@ -0,0 +1,106 @@
+package httx
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestETag(t *testing.T) {
+	r := NewMux()
+	r.Pre(ETagMaxSize(16))
+	r.GET("/", func(w http.ResponseWriter, r *http.Request) error {
+		_, err := w.Write([]byte("hello"))
+		return err
+	})
+	r.GET("/created", func(w http.ResponseWriter, r *http.Request) error {
+		w.WriteHeader(http.StatusCreated)
+		_, err := w.Write([]byte("created"))
+		return err
+	})
+	r.GET("/large", func(w http.ResponseWriter, r *http.Request) error {
+		_, err := w.Write([]byte(strings.Repeat("a", 32)))
+		return err
+	})
+
+	rec := r.TestRequest(http.MethodGet, "/", nil)
+	etag := rec.Header().Get("ETag")
+	if rec.Code != http.StatusOK || rec.Body.String() != "hello" || etag == "" {
+		t.Fatalf("first request: got %d %q with ETag %q, want 200 \"hello\" with an ETag", rec.Code, rec.Body.String(), etag)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("If-None-Match", `"other", `+etag)
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotModified || rec.Body.Len() != 0 {
+		t.Errorf("matching If-None-Match: got %d %q, want 304 without a body", rec.Code, rec.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("If-None-Match", `"other"`)
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || rec.Body.String() != "hello" {
+		t.Errorf("non-matching If-None-Match: got %d %q, want 200 \"hello\"", rec.Code, rec.Body.String())
+	}
+
+	rec = r.TestRequest(http.MethodGet, "/created", nil)
+	if rec.Code != http.StatusCreated || rec.Body.String() != "created" {
+		t.Errorf("non-200 response: got %d %q, want 201 \"created\"", rec.Code, rec.Body.String())
+	}
+
+	rec = r.TestRequest(http.MethodGet, "/large", nil)
+	if rec.Code != http.StatusOK || rec.Body.Len() != 32 || rec.Header().Get("ETag") != "" {
+		t.Errorf("response over max size: got %d with %d bytes and ETag %q, want 200 with 32 bytes and no ETag", rec.Code, rec.Body.Len(), rec.Header().Get("ETag"))
+	}
+}
+
+func TestNotModifiedSince(t *testing.T) {
+	modtime := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name, header, value string
+		etag                string
+		want                bool
+	}{
+		{"fresh by date", "If-Modified-Since", modtime.Format(http.TimeFormat), "", true},
+		{"fresh by later date", "If-Modified-Since", modtime.Add(time.Hour).Format(http.TimeFormat), "", true},
+		{"stale by date", "If-Modified-Since", modtime.Add(-time.Hour).Format(http.TimeFormat), "", false},
+		{"fresh by etag", "If-None-Match", `"v2"`, "v2", true},
+		{"stale by etag", "If-None-Match", `"v1"`, "v2", false},
+		{"no conditions", "", "", "v2", false},
+	}
+
+	for _, test := range tests {
+		r := NewMux()
+		reached := false
+		r.GET("/", func(w http.ResponseWriter, r *http.Request) error {
+			if NotModifiedSince(w, r, modtime, test.etag) {
+				return nil
+			}
+			reached = true
+			_, err := w.Write([]byte("content"))
+			return err
+		})
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if test.header != "" {
+			req.Header.Set(test.header, test.value)
+		}
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+
+		if test.want && (rec.Code != http.StatusNotModified || reached) {
+			t.Errorf("%s: got %d, handler reached %v, want 304 without reaching it", test.name, rec.Code, reached)
+		}
+		if !test.want && (rec.Code != http.StatusOK || rec.Body.String() != "content") {
+			t.Errorf("%s: got %d %q, want 200 \"content\"", test.name, rec.Code, rec.Body.String())
+		}
+		if got := rec.Header().Get("Last-Modified"); got != modtime.Format(http.TimeFormat) {
+			t.Errorf("%s: Last-Modified == %q, want %q", test.name, got, modtime.Format(http.TimeFormat))
+		}
+	}
+}
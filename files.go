@@ -0,0 +1,210 @@
+package httx
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// ServeFiles serves the files of the root directory at the given path, which
+// must end with "{filepath:*}". Failures are handled by Mux.OnError.
+//
+// Files are served with http.ServeContent, thus range requests and
+// conditional ones, If-Range included, are supported. Precompressed siblings
+// of the files are served instead if Mux.ServePrecompressed is enabled.
+func (m *Mux) ServeFiles(path, root string) {
+	m.ServeFilesCustom(path, os.DirFS(root), nil)
+}
+
+// ServeFS serves the files of fsys at the given path, which must end with
+// "{filepath:*}", as ServeFiles does.
+func (m *Mux) ServeFS(path string, fsys fs.FS) {
+	m.ServeFilesCustom(path, fsys, nil)
+}
+
+// ServeFilesCustom serves files from fsys at the given path, which must end
+// with "{filepath:*}", e.g. "/static/{filepath:*}". Directories are not
+// listed and respond as missing files.
+//
+// Failures are passed to onError as an *HTTPError wrapping the cause, so
+// fs.ErrNotExist and friends can still be checked for with errors.Is. If
+// onError is nil, they are handled by Mux.OnError.
+func (m *Mux) ServeFilesCustom(path string, fsys fs.FS, onError func(http.ResponseWriter, *http.Request, error)) {
+	if !strings.HasSuffix(path, "{filepath:*}") {
+		panic("path must end with {filepath:*} in path '" + path + "'")
+	}
+
+	m.GET(path, func(w http.ResponseWriter, r *http.Request) error {
+		err := serveFile(w, r, fsys, r.PathValue("filepath"), m.serving(w).ServePrecompressed)
+		if err != nil && onError != nil {
+			onError(w, r, err)
+			return nil
+		}
+		return err
+	})
+}
+
+// ServeFile registers a handler serving the single file at filePath, e.g. for
+// "/robots.txt". Like with ServeFiles, the content type is derived from the
+// name, conditional and range requests are supported, and failures, such as
+// the file missing with 404, are handled by Mux.OnError.
+func (m *Mux) ServeFile(method, path, filePath string) {
+	dir, name := filepath.Split(filePath)
+	if name == "" {
+		panic("file path must not end with a separator in file path '" + filePath + "'")
+	}
+	if dir == "" {
+		dir = "."
+	}
+	fsys := os.DirFS(dir)
+
+	m.Handle(method, path, func(w http.ResponseWriter, r *http.Request) error {
+		return serveFile(w, r, fsys, name, m.serving(w).ServePrecompressed)
+	})
+}
+
+// serveFile writes the named file of fsys with http.ServeContent, or its
+// precompressed sibling if enabled and acceptable
+func serveFile(w http.ResponseWriter, r *http.Request, fsys fs.FS, name string, precompressed bool) error {
+	name, ok := cleanFilePath(name)
+	if !ok {
+		return &HTTPError{Code: http.StatusBadRequest, Err: fmt.Errorf("invalid file path %q: %w", name, fs.ErrInvalid)}
+	}
+
+	f, err := fsys.Open(name)
+	if err != nil {
+		return fileError(err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return fileError(err)
+	}
+	if info.IsDir() {
+		return &HTTPError{Code: http.StatusNotFound, Err: fs.ErrNotExist}
+	}
+
+	if precompressed {
+		Vary(w, "Accept-Encoding")
+		if served, err := servePrecompressed(w, r, fsys, name); served || err != nil {
+			return err
+		}
+	}
+
+	return serveContent(w, r, f, info)
+}
+
+// precompressedEncodings are the encodings of precompressed siblings, in
+// order of preference
+var precompressedEncodings = []struct{ coding, ext string }{
+	{"br", ".br"},
+	{"gzip", ".gz"},
+}
+
+// servePrecompressed writes the first sibling of the named file in an
+// encoding the client accepts, reporting whether there was one. Files of
+// unknown content types are skipped, as it can't be sniffed from the
+// compressed content.
+func servePrecompressed(w http.ResponseWriter, r *http.Request, fsys fs.FS, name string) (bool, error) {
+	contentType := mime.TypeByExtension(path.Ext(name))
+	if contentType == "" {
+		return false, nil
+	}
+
+	specs := parseAccept(r.Header.Get("Accept-Encoding"))
+	for _, enc := range precompressedEncodings {
+		if !acceptsEncoding(specs, enc.coding) {
+			continue
+		}
+
+		f, err := fsys.Open(name + enc.ext)
+		if err != nil {
+			continue
+		}
+		defer f.Close()
+
+		info, err := f.Stat()
+		if err != nil || info.IsDir() {
+			continue
+		}
+
+		h := w.Header()
+		h.Set("Content-Encoding", enc.coding)
+		if h.Get("Content-Type") == "" {
+			h.Set("Content-Type", contentType)
+		}
+		return true, serveContent(w, r, f, info)
+	}
+
+	return false, nil
+}
+
+// acceptsEncoding reports whether the content coding has a non-zero weight,
+// the weight of an exact match taking precedence over the one of "*"
+func acceptsEncoding(specs []acceptSpec, coding string) bool {
+	q := -1.0
+	for _, spec := range specs {
+		switch {
+		case strings.EqualFold(spec.value, coding):
+			return spec.q > 0
+		case spec.value == "*":
+			q = spec.q
+		}
+	}
+	return q > 0
+}
+
+// serveContent writes the opened file with http.ServeContent
+func serveContent(w http.ResponseWriter, r *http.Request, f fs.File, info fs.FileInfo) error {
+	content, ok := f.(io.ReadSeeker)
+	if !ok {
+		b, err := io.ReadAll(f)
+		if err != nil {
+			return fileError(err)
+		}
+		content = bytes.NewReader(b)
+	}
+
+	http.ServeContent(w, r, info.Name(), info.ModTime(), content)
+	return nil
+}
+
+// cleanFilePath cleans the already decoded name into a path valid for
+// fs.FS. Names with ".." segments are rejected regardless of whether they
+// would escape the root, as are backslashes and NUL bytes.
+func cleanFilePath(name string) (string, bool) {
+	if strings.ContainsAny(name, "\\\x00") || slices.Contains(strings.Split(name, "/"), "..") {
+		return name, false
+	}
+
+	name = path.Clean("/" + name)[1:]
+	if name == "" {
+		name = "."
+	}
+
+	return name, fs.ValidPath(name)
+}
+
+// fileError wraps err into an *HTTPError with the matching status code
+func fileError(err error) error {
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return &HTTPError{Code: http.StatusNotFound, Err: err}
+	case errors.Is(err, fs.ErrPermission):
+		return &HTTPError{Code: http.StatusForbidden, Err: err}
+	case errors.Is(err, fs.ErrInvalid):
+		return &HTTPError{Code: http.StatusBadRequest, Err: err}
+	default:
+		return &HTTPError{Code: http.StatusInternalServerError, Err: err}
+	}
+}
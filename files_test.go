@@ -0,0 +1,250 @@
+package httx
+
+import (
+	"errors"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestServeFilesCustom(t *testing.T) {
+	fsys := fstest.MapFS{
+		"favicon.ico":     {Data: []byte("fake ico")},
+		"css/style.css":   {Data: []byte("body{}")},
+		"secret/password": {Data: []byte("hunter2")},
+	}
+
+	r := NewMux()
+
+	if recv := catchPanic(func() { r.ServeFilesCustom("/noFilepath", fsys, nil) }); recv == nil {
+		t.Fatal("registering path not ending with '{filepath:*}' did not panic")
+	}
+
+	var errs []error
+	r.ServeFilesCustom("/static/{filepath:*}", fsys, func(w http.ResponseWriter, r *http.Request, err error) {
+		errs = append(errs, err)
+		w.WriteHeader(statusOf(err))
+	})
+
+	tests := []struct {
+		path string
+		code int
+		body string
+		err  error
+	}{
+		{"/static/favicon.ico", http.StatusOK, "fake ico", nil},
+		{"/static/css/style.css", http.StatusOK, "body{}", nil},
+		{"/static/missing.txt", http.StatusNotFound, "", fs.ErrNotExist},
+		{"/static/css", http.StatusNotFound, "", fs.ErrNotExist},
+		{"/static/css/../secret/password", http.StatusBadRequest, "", fs.ErrInvalid},
+	}
+
+	for _, test := range tests {
+		errs = nil
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, test.path, nil))
+
+		if rec.Code != test.code {
+			t.Errorf("GET %s: got %d, want %d", test.path, rec.Code, test.code)
+		}
+		if test.body != "" && rec.Body.String() != test.body {
+			t.Errorf("GET %s: got body %q, want %q", test.path, rec.Body.String(), test.body)
+		}
+		if test.err == nil && len(errs) > 0 || test.err != nil && (len(errs) != 1 || !errors.Is(errs[0], test.err)) {
+			t.Errorf("GET %s: onError got %v, want %v", test.path, errs, test.err)
+		}
+	}
+}
+
+func TestServeFilesTraversal(t *testing.T) {
+	fsys := fstest.MapFS{
+		"public/index.html": {Data: []byte("index")},
+		"public/a/b.txt":    {Data: []byte("b")},
+		"secret":            {Data: []byte("hunter2")},
+	}
+
+	r := NewMux()
+	sub, _ := fs.Sub(fsys, "public")
+	r.ServeFilesCustom("/static/{filepath:*}", sub, nil)
+
+	tests := []struct {
+		path string
+		code int
+	}{
+		{"/static/../../etc/passwd", http.StatusBadRequest},
+		{"/static/%2e%2e/secret", http.StatusBadRequest},
+		{"/static/a/%2e%2e/%2e%2e/secret", http.StatusBadRequest},
+		{"/static/a%5c..%5csecret", http.StatusBadRequest},
+		{"/static/a//b.txt", http.StatusOK},
+		{"/static/./a/b.txt", http.StatusOK},
+	}
+
+	for _, test := range tests {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, test.path, nil))
+
+		if rec.Code != test.code {
+			t.Errorf("GET %s: got %d, want %d", test.path, rec.Code, test.code)
+		}
+		if strings.Contains(rec.Body.String(), "hunter2") {
+			t.Errorf("GET %s: leaked a file outside of the root", test.path)
+		}
+	}
+}
+
+func TestServeFilesRange(t *testing.T) {
+	modtime := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	dir := t.TempDir()
+	name := filepath.Join(dir, "video.mp4")
+	if err := os.WriteFile(name, []byte("0123456789"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(name, modtime, modtime); err != nil {
+		t.Fatal(err)
+	}
+
+	r := NewMux()
+	r.ServeFiles("/files/{filepath:*}", dir)
+	r.ServeFS("/fs/{filepath:*}", fstest.MapFS{
+		"video.mp4": {Data: []byte("0123456789"), ModTime: modtime},
+	})
+
+	tests := []struct {
+		rangeHeader, ifRange string
+		code                 int
+		contentRange, body   string
+	}{
+		{"bytes=0-3", "", http.StatusPartialContent, "bytes 0-3/10", "0123"},
+		{"bytes=7-", "", http.StatusPartialContent, "bytes 7-9/10", "789"},
+		{"bytes=20-", "", http.StatusRequestedRangeNotSatisfiable, "bytes */10", ""},
+		{"bytes=0-3", modtime.Format(http.TimeFormat), http.StatusPartialContent, "bytes 0-3/10", "0123"},
+		{"bytes=0-3", modtime.Add(-time.Hour).Format(http.TimeFormat), http.StatusOK, "", "0123456789"},
+	}
+
+	for _, prefix := range []string{"/files", "/fs"} {
+		for _, test := range tests {
+			req := httptest.NewRequest(http.MethodGet, prefix+"/video.mp4", nil)
+			req.Header.Set("Range", test.rangeHeader)
+			if test.ifRange != "" {
+				req.Header.Set("If-Range", test.ifRange)
+			}
+
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, req)
+
+			if rec.Code != test.code {
+				t.Errorf("%s Range %s If-Range %q: got %d, want %d", prefix, test.rangeHeader, test.ifRange, rec.Code, test.code)
+			}
+			if got := rec.Header().Get("Content-Range"); got != test.contentRange {
+				t.Errorf("%s Range %s If-Range %q: Content-Range == %q, want %q", prefix, test.rangeHeader, test.ifRange, got, test.contentRange)
+			}
+			if test.body != "" && rec.Body.String() != test.body {
+				t.Errorf("%s Range %s If-Range %q: got body %q, want %q", prefix, test.rangeHeader, test.ifRange, rec.Body.String(), test.body)
+			}
+		}
+	}
+}
+
+func TestServeFile(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "robots.txt")
+	if err := os.WriteFile(name, []byte("User-agent: *\nDisallow:\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	r := NewMux()
+	r.ServeFile(http.MethodGet, "/robots.txt", name)
+	r.ServeFile(http.MethodGet, "/favicon.ico", filepath.Join(dir, "favicon.ico"))
+
+	rec := r.TestRequest(http.MethodGet, "/robots.txt", nil)
+	if rec.Code != http.StatusOK || rec.Body.String() != "User-agent: *\nDisallow:\n" {
+		t.Errorf("GET /robots.txt: got %d %q", rec.Code, rec.Body.String())
+	}
+	if got := rec.Header().Get("Content-Type"); !strings.HasPrefix(got, "text/plain") {
+		t.Errorf("GET /robots.txt: Content-Type == %q, want text/plain", got)
+	}
+	if rec.Header().Get("Last-Modified") == "" {
+		t.Error("GET /robots.txt: no Last-Modified header")
+	}
+
+	if rec := r.TestRequest(http.MethodGet, "/favicon.ico", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("GET /favicon.ico: got %d, want %d", rec.Code, http.StatusNotFound)
+	}
+
+	if recv := catchPanic(func() { r.ServeFile(http.MethodGet, "/dir", dir+"/") }); recv == nil {
+		t.Error("serving a directory path did not panic")
+	}
+}
+
+func TestServePrecompressed(t *testing.T) {
+	fsys := fstest.MapFS{
+		"app.js":          {Data: []byte("plain js")},
+		"app.js.gz":       {Data: []byte("gzipped js")},
+		"app.css":         {Data: []byte("plain css")},
+		"app.css.gz":      {Data: []byte("gzipped css")},
+		"app.css.br":      {Data: []byte("brotli css")},
+		"index.html":      {Data: []byte("plain html")},
+		"data.unknown":    {Data: []byte("plain data")},
+		"data.unknown.gz": {Data: []byte("gzipped data")},
+	}
+
+	r := NewMux()
+	r.ServePrecompressed = true
+	r.ServeFS("/static/{filepath:*}", fsys)
+
+	tests := []struct {
+		path, acceptEncoding string
+		body, encoding       string
+	}{
+		{"/static/app.js", "gzip, deflate", "gzipped js", "gzip"},
+		{"/static/app.js", "", "plain js", ""},
+		{"/static/app.js", "br", "plain js", ""},
+		{"/static/app.js", "gzip;q=0, *", "plain js", ""},
+		{"/static/app.css", "gzip, br", "brotli css", "br"},
+		{"/static/app.css", "gzip, br;q=0", "gzipped css", "gzip"},
+		{"/static/index.html", "gzip, br", "plain html", ""},
+		{"/static/data.unknown", "gzip", "plain data", ""},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest(http.MethodGet, test.path, nil)
+		if test.acceptEncoding != "" {
+			req.Header.Set("Accept-Encoding", test.acceptEncoding)
+		}
+
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusOK || rec.Body.String() != test.body {
+			t.Errorf("GET %s with Accept-Encoding %q: got %d %q, want %d %q", test.path, test.acceptEncoding, rec.Code, rec.Body.String(), http.StatusOK, test.body)
+		}
+		if got := rec.Header().Get("Content-Encoding"); got != test.encoding {
+			t.Errorf("GET %s with Accept-Encoding %q: Content-Encoding == %q, want %q", test.path, test.acceptEncoding, got, test.encoding)
+		}
+		if got := rec.Header().Get("Vary"); got != "Accept-Encoding" {
+			t.Errorf("GET %s with Accept-Encoding %q: Vary == %q, want %q", test.path, test.acceptEncoding, got, "Accept-Encoding")
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/static/app.js", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	if got := rec.Header().Get("Content-Type"); !strings.HasPrefix(got, "text/javascript") {
+		t.Errorf("GET /static/app.js gzipped: Content-Type == %q, want text/javascript", got)
+	}
+
+	r.ServePrecompressed = false
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	if rec.Body.String() != "plain js" || rec.Header().Get("Content-Encoding") != "" {
+		t.Errorf("GET /static/app.js with ServePrecompressed disabled: got %q encoded with %q", rec.Body.String(), rec.Header().Get("Content-Encoding"))
+	}
+}
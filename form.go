@@ -0,0 +1,60 @@
+package httx
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+)
+
+// defaultMaxMultipartMemory is the one used by http.Request.FormFile
+const defaultMaxMultipartMemory = 32 << 20
+
+// FormFile returns the first file uploaded for the form field, parsing the
+// multipart form with MaxMultipartMemory if needed. A missing field or a
+// malformed form is returned as a 400 *HTTPError, wrapping
+// http.ErrMissingFile in the former case.
+func (m *Mux) FormFile(r *http.Request, name string) (multipart.File, *multipart.FileHeader, error) {
+	if r.MultipartForm == nil {
+		maxMemory := m.MaxMultipartMemory
+		if maxMemory <= 0 {
+			maxMemory = defaultMaxMultipartMemory
+		}
+
+		if err := r.ParseMultipartForm(maxMemory); err != nil {
+			return nil, nil, &HTTPError{Code: http.StatusBadRequest, Err: fmt.Errorf("invalid multipart form: %w", err)}
+		}
+	}
+
+	f, fh, err := r.FormFile(name)
+	if errors.Is(err, http.ErrMissingFile) {
+		return nil, nil, &HTTPError{Code: http.StatusBadRequest, Err: fmt.Errorf("form field %q: %w", name, err)}
+	}
+
+	return f, fh, err
+}
+
+// SaveUploadedFile copies the uploaded file to dst, creating or truncating it.
+// Beware that fh.Filename is chosen by the client, thus unsafe to build dst
+// from as is.
+func SaveUploadedFile(fh *multipart.FileHeader, dst string) error {
+	src, err := fh.Open()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(out, src); err != nil {
+		out.Close()
+		return err
+	}
+
+	return out.Close()
+}
@@ -0,0 +1,62 @@
+package httx
+
+import (
+	"bytes"
+	"errors"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFormFile(t *testing.T) {
+	dir := t.TempDir()
+
+	m := NewMux()
+	m.MaxMultipartMemory = 1 << 10
+
+	var formErr error
+	m.POST("/upload", func(w http.ResponseWriter, r *http.Request) error {
+		f, fh, err := m.FormFile(r, "avatar")
+		if err != nil {
+			formErr = err
+			return err
+		}
+		defer f.Close()
+
+		return SaveUploadedFile(fh, filepath.Join(dir, filepath.Base(fh.Filename)))
+	})
+
+	upload := func(field string) *httptest.ResponseRecorder {
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		fw, _ := mw.CreateFormFile(field, "gopher.png")
+		fw.Write([]byte("not really a png"))
+		mw.Close()
+
+		req := httptest.NewRequest(http.MethodPost, "/upload", &body)
+		req.Header.Set("Content-Type", mw.FormDataContentType())
+
+		rec := httptest.NewRecorder()
+		m.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := upload("avatar"); rec.Code != http.StatusOK {
+		t.Fatalf("upload: got %d %q, want 200", rec.Code, rec.Body.String())
+	}
+
+	saved, err := os.ReadFile(filepath.Join(dir, "gopher.png"))
+	if err != nil || string(saved) != "not really a png" {
+		t.Errorf("saved file == %q, %v, want %q", saved, err, "not really a png")
+	}
+
+	if rec := upload("other"); rec.Code != http.StatusBadRequest {
+		t.Errorf("missing field: got %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+	if !errors.Is(formErr, http.ErrMissingFile) {
+		t.Errorf("missing field: got error %v, want http.ErrMissingFile", formErr)
+	}
+}
@@ -1,6 +1,8 @@
 package httx
 
 import (
+	"context"
+	"errors"
 	"log/slog"
 	"net/http"
 	"time"
@@ -12,13 +14,58 @@ func init() {
 	DefaultServeMux.Pre(DefaultSlogMiddleware())
 }
 
+// DefaultSlogMiddleware logs every request with the default slog logger, see
+// SlogMiddleware.
 func DefaultSlogMiddleware() func(HandlerFunc) HandlerFunc {
+	return SlogMiddleware(nil)
+}
+
+// SlogMiddleware logs every request with the logger, or the default one if
+// nil, including the matched route, the status and the size of the response.
+//
+// For failed requests, whose response is written by OnError after the
+// middleware returns, the status is the one OnError is expected to write.
+func SlogMiddleware(logger *slog.Logger) func(HandlerFunc) HandlerFunc {
 	return func(next HandlerFunc) HandlerFunc {
-		return func(w http.ResponseWriter, r *http.Request) error {
+		return func(w http.ResponseWriter, r *http.Request) (err error) {
 			start := time.Now()
 			defer func() {
 				finish := time.Now()
-				slog.Info("request", "method", r.Method, "uri", r.RequestURI, "time-ms", finish.Sub(start).Milliseconds())
+
+				l := logger
+				if l == nil {
+					l = slog.Default()
+				}
+
+				var (
+					route  string
+					status = http.StatusOK
+					size   int64
+				)
+				rw, ok := AsResponseWriter(w)
+				if ok {
+					route, size = rw.Route(), rw.Size()
+				}
+				switch {
+				case ok && rw.Written():
+					status = rw.Status()
+				case err != nil:
+					status = statusOf(err)
+				}
+
+				attrs := []any{
+					"method", r.Method,
+					"uri", r.RequestURI,
+					"route", route,
+					"status", status,
+					"bytes", size,
+					"remote_addr", r.RemoteAddr,
+					"time-ms", finish.Sub(start).Milliseconds(),
+				}
+				if err != nil {
+					attrs = append(attrs, "error", err)
+				}
+				l.Info("request", attrs...)
 			}()
 			return next(w, r)
 		}
@@ -72,3 +119,44 @@ func ANY(path string, handler HandlerFunc) {
 func Merge(path string, handler http.Handler) {
 	DefaultServeMux.Merge(path, handler)
 }
+
+// SlowLog logs a warning with the logger, or the default one if nil, for
+// requests taking longer than the threshold, including the matched route and
+// the duration. Requests cut off by a context deadline, see
+// Mux.RequestTimeout, are marked as such.
+func SlowLog(threshold time.Duration, logger *slog.Logger) func(HandlerFunc) HandlerFunc {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) error {
+			start := time.Now()
+			err := next(w, r)
+
+			elapsed := time.Since(start)
+			if elapsed <= threshold {
+				return err
+			}
+
+			l := logger
+			if l == nil {
+				l = slog.Default()
+			}
+
+			var route string
+			if rw, ok := AsResponseWriter(w); ok {
+				route = rw.Route()
+			}
+
+			attrs := []any{
+				"method", r.Method,
+				"uri", r.RequestURI,
+				"route", route,
+				"duration", elapsed,
+			}
+			if errors.Is(r.Context().Err(), context.DeadlineExceeded) {
+				attrs = append(attrs, "deadline_exceeded", true)
+			}
+			l.Warn("slow request", attrs...)
+
+			return err
+		}
+	}
+}
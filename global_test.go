@@ -0,0 +1,96 @@
+package httx
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSlogMiddleware(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+
+	r := NewMux()
+	r.Pre(SlogMiddleware(logger))
+	r.GET("/users/{id}", func(w http.ResponseWriter, r *http.Request) error {
+		w.WriteHeader(http.StatusCreated)
+		_, err := w.Write([]byte("hello"))
+		return err
+	})
+	r.GET("/fail", func(w http.ResponseWriter, r *http.Request) error {
+		return &HTTPError{Code: http.StatusForbidden, Err: errors.New("no")}
+	})
+
+	tests := []struct {
+		path, route string
+		status      float64
+		bytes       float64
+	}{
+		{"/users/42", "/users/{id}", http.StatusCreated, 5},
+		{"/fail", "/fail", http.StatusForbidden, 0},
+	}
+
+	for _, test := range tests {
+		buf.Reset()
+
+		req := httptest.NewRequest(http.MethodGet, test.path, nil)
+		req.RemoteAddr = "10.0.0.1:1234"
+		r.ServeHTTP(httptest.NewRecorder(), req)
+
+		var entry map[string]any
+		if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+			t.Fatalf("GET %s: unexpected log output %q: %v", test.path, buf.String(), err)
+		}
+
+		want := map[string]any{
+			"route":       test.route,
+			"status":      test.status,
+			"bytes":       test.bytes,
+			"remote_addr": "10.0.0.1:1234",
+			"method":      http.MethodGet,
+		}
+		for k, v := range want {
+			if entry[k] != v {
+				t.Errorf("GET %s: %s == %v, want %v", test.path, k, entry[k], v)
+			}
+		}
+	}
+}
+
+func TestSlowLog(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+
+	r := NewMux()
+	r.Pre(SlowLog(20*time.Millisecond, logger))
+	r.GET("/fast", func(w http.ResponseWriter, r *http.Request) error {
+		return nil
+	})
+	r.GET("/slow/{id}", func(w http.ResponseWriter, r *http.Request) error {
+		time.Sleep(30 * time.Millisecond)
+		return nil
+	})
+
+	r.TestRequest(http.MethodGet, "/fast", nil)
+	if buf.Len() != 0 {
+		t.Errorf("GET /fast: logged %q, want nothing", buf.String())
+	}
+
+	r.TestRequest(http.MethodGet, "/slow/1", nil)
+
+	var entry map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("GET /slow/1: unexpected log output %q: %v", buf.String(), err)
+	}
+	if entry["level"] != "WARN" || entry["route"] != "/slow/{id}" {
+		t.Errorf("GET /slow/1: level == %v, route == %v, want WARN, /slow/{id}", entry["level"], entry["route"])
+	}
+	if d, _ := entry["duration"].(float64); time.Duration(d) < 30*time.Millisecond {
+		t.Errorf("GET /slow/1: duration == %v, want at least 30ms", entry["duration"])
+	}
+}
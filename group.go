@@ -3,64 +3,146 @@ package httx
 import (
 	"net/http"
 	"strings"
+
+	"github.com/sirkostya009/httx/radix"
 )
 
 type Group struct {
-	prefix string
-	m      *Mux
+	prefix  string
+	m       *Mux
+	scope   *groupScope
+	onError func(http.ResponseWriter, *http.Request, error)
+}
+
+// groupScope holds the handlers of a group used for unmatched requests
+// within its prefix
+type groupScope struct {
+	prefix             string
+	onNotFound         func(http.ResponseWriter, *http.Request)
+	onMethodNotAllowed func(http.ResponseWriter, *http.Request)
 }
 
+// ServeHTTP is never called, it only allows storing scopes in a radix.Tree
+func (*groupScope) ServeHTTP(http.ResponseWriter, *http.Request) {}
+
 func (g *Group) Group(prefix string) *Group {
 	if !strings.HasPrefix(prefix, "/") {
 		panic(`group prefix must begin with "/"`)
 	}
-	return &Group{g.prefix + prefix, g.m}
+	return &Group{g.prefix + prefix, g.m, g.scope, g.onError}
+}
+
+// OnError sets the error handler for routes registered through the group
+// afterwards, instead of Mux.OnError.
+func (g *Group) OnError(h func(http.ResponseWriter, *http.Request, error)) {
+	g.onError = h
+}
+
+// OnNotFound sets the handler for unmatched requests within the group
+// prefix, instead of Mux.OnNotFound.
+func (g *Group) OnNotFound(h func(http.ResponseWriter, *http.Request)) {
+	g.ownScope().onNotFound = h
+}
+
+// OnMethodNotAllowed sets the handler for requests within the group prefix
+// matching routes of other methods only, instead of Mux.OnMethodNotAllowed.
+func (g *Group) OnMethodNotAllowed(h func(http.ResponseWriter, *http.Request)) {
+	g.ownScope().onMethodNotAllowed = h
+}
+
+// ownScope returns the scope of the group, registering it with the Mux if
+// it's inherited from the parent group
+func (g *Group) ownScope() *groupScope {
+	if g.scope != nil && g.scope.prefix == g.prefix {
+		return g.scope
+	}
+
+	scope := &groupScope{prefix: g.prefix}
+	if g.scope != nil {
+		scope.onNotFound = g.scope.onNotFound
+		scope.onMethodNotAllowed = g.scope.onMethodNotAllowed
+	}
+	g.scope = scope
+
+	if g.m.scopes == nil {
+		g.m.scopes = radix.New()
+		g.m.scopes.Mutable = true
+	}
+
+	base := strings.TrimSuffix(g.prefix, "/")
+	if base != "" {
+		g.m.scopes.Add(base, scope)
+	}
+	g.m.scopes.Add(base+"/{_scope:*}", scope)
+
+	return scope
+}
+
+// wrap routes errors of the handler to the group error handler, if any
+func (g *Group) wrap(handler HandlerFunc) HandlerFunc {
+	onError := g.onError
+	if onError == nil || handler == nil {
+		return handler
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) error {
+		if err := handler(w, r); err != nil {
+			onError(w, r, err)
+		}
+		return nil
+	}
 }
 
 func (g *Group) Handle(method, path string, handler HandlerFunc) {
-	g.m.Handle(method, g.prefix+path, handler)
+	g.m.Handle(method, g.prefix+path, g.wrap(handler))
 }
 
 func (g *Group) GET(path string, handler HandlerFunc) {
-	g.m.GET(g.prefix+path, handler)
+	g.m.GET(g.prefix+path, g.wrap(handler))
 }
 
 func (g *Group) POST(path string, handler HandlerFunc) {
-	g.m.POST(g.prefix+path, handler)
+	g.m.POST(g.prefix+path, g.wrap(handler))
 }
 
 func (g *Group) PUT(path string, handler HandlerFunc) {
-	g.m.PUT(g.prefix+path, handler)
+	g.m.PUT(g.prefix+path, g.wrap(handler))
 }
 
 func (g *Group) PATCH(path string, handler HandlerFunc) {
-	g.m.PATCH(g.prefix+path, handler)
+	g.m.PATCH(g.prefix+path, g.wrap(handler))
 }
 
 func (g *Group) DELETE(path string, handler HandlerFunc) {
-	g.m.DELETE(g.prefix+path, handler)
+	g.m.DELETE(g.prefix+path, g.wrap(handler))
 }
 
 func (g *Group) HEAD(path string, handler HandlerFunc) {
-	g.m.HEAD(g.prefix+path, handler)
+	g.m.HEAD(g.prefix+path, g.wrap(handler))
 }
 
 func (g *Group) CONNECT(path string, handler HandlerFunc) {
-	g.m.CONNECT(g.prefix+path, handler)
+	g.m.CONNECT(g.prefix+path, g.wrap(handler))
 }
 
 func (g *Group) OPTIONS(path string, handler HandlerFunc) {
-	g.m.OPTIONS(g.prefix+path, handler)
+	g.m.OPTIONS(g.prefix+path, g.wrap(handler))
 }
 
 func (g *Group) TRACE(path string, handler HandlerFunc) {
-	g.m.TRACE(g.prefix+path, handler)
+	g.m.TRACE(g.prefix+path, g.wrap(handler))
 }
 
 func (g *Group) ANY(path string, handler HandlerFunc) {
-	g.m.ANY(g.prefix+path, handler)
+	g.m.ANY(g.prefix+path, g.wrap(handler))
 }
 
 func (g *Group) Merge(path string, handler http.Handler) {
 	g.m.Merge(g.prefix+path, handler)
 }
+
+// Mount mounts sub at the prefix within the group, see Mux.Mount. The path
+// sub sees is stripped of both the group prefix and the mount prefix.
+func (g *Group) Mount(prefix string, sub *Mux) {
+	g.m.Mount(g.prefix+prefix, sub)
+}
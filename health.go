@@ -0,0 +1,26 @@
+package httx
+
+import "net/http"
+
+// RegisterHealth registers k8s-style probes: the liveness one at livePath,
+// always responding with 200, and the readiness one at readyPath, responding
+// with 200 if readyCheck returns nil, 503 with the error message otherwise.
+// A nil readyCheck means always ready.
+func (m *Mux) RegisterHealth(livePath, readyPath string, readyCheck func() error) {
+	m.GET(livePath, func(w http.ResponseWriter, r *http.Request) error {
+		_, err := w.Write([]byte("ok"))
+		return err
+	})
+
+	m.GET(readyPath, func(w http.ResponseWriter, r *http.Request) error {
+		if readyCheck != nil {
+			if err := readyCheck(); err != nil {
+				http.Error(w, err.Error(), http.StatusServiceUnavailable)
+				return nil
+			}
+		}
+
+		_, err := w.Write([]byte("ok"))
+		return err
+	})
+}
@@ -0,0 +1,34 @@
+package httx
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestRegisterHealth(t *testing.T) {
+	var notReady error
+
+	r := NewMux()
+	r.RegisterHealth("/livez", "/readyz", func() error { return notReady })
+
+	if rec := r.TestRequest(http.MethodGet, "/livez", nil); rec.Code != http.StatusOK {
+		t.Errorf("GET /livez: got %d, want %d", rec.Code, http.StatusOK)
+	}
+	if rec := r.TestRequest(http.MethodGet, "/readyz", nil); rec.Code != http.StatusOK {
+		t.Errorf("GET /readyz: got %d, want %d", rec.Code, http.StatusOK)
+	}
+
+	notReady = errors.New("database unreachable")
+	rec := r.TestRequest(http.MethodGet, "/readyz", nil)
+	if rec.Code != http.StatusServiceUnavailable || strings.TrimSpace(rec.Body.String()) != "database unreachable" {
+		t.Errorf("GET /readyz while not ready: got %d %q, want 503 %q", rec.Code, rec.Body.String(), "database unreachable")
+	}
+
+	r = NewMux()
+	r.RegisterHealth("/livez", "/readyz", nil)
+	if rec := r.TestRequest(http.MethodGet, "/readyz", nil); rec.Code != http.StatusOK {
+		t.Errorf("GET /readyz without a check: got %d, want %d", rec.Code, http.StatusOK)
+	}
+}
@@ -0,0 +1,140 @@
+package httx
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"reflect"
+)
+
+// JSONMethodNotAllowed is an alternative to DefaultOnMethodNotAllowed, that
+// writes the methods from the Allow header as a JSON body.
+func JSONMethodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	allowed := w.Header().Values("Allow")
+	if allowed == nil {
+		allowed = []string{}
+	}
+
+	writeJSON(w, http.StatusMethodNotAllowed, struct {
+		Error   string   `json:"error"`
+		Allowed []string `json:"allowed"`
+	}{"method not allowed", allowed})
+}
+
+// JSONNotFound is an alternative to DefaultOnNotFound with a JSON body.
+func JSONNotFound(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusNotFound, struct {
+		Error string `json:"error"`
+	}{"not found"})
+}
+
+// DefaultJSONErrorHandler is an alternative to DefaultErrorHandler, that
+// writes the error as a JSON body along with the ID assigned by the RequestID
+// middleware, if any, for clients to refer to:
+//
+//	{"error":"...","request_id":"..."}
+//
+// Messages are hidden as with DefaultErrorHandler, see Mux.ExposeErrors.
+func DefaultJSONErrorHandler(w http.ResponseWriter, r *http.Request, err error) {
+	code := statusOf(err)
+	if code >= 500 {
+		slog.Error("error", "method", r.Method, "uri", r.RequestURI, "error", err)
+	}
+
+	writeJSON(w, code, struct {
+		Error     string `json:"error"`
+		RequestID string `json:"request_id,omitempty"`
+	}{errorMessage(w, err, code), RequestIDFrom(w)})
+}
+
+func writeJSON(w http.ResponseWriter, code int, body any) error {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	return json.NewEncoder(w).Encode(body)
+}
+
+// HandleJSON registers fn, whose returned status and body are written as a
+// JSON response. Errors are passed to OnError as with any other HandlerFunc.
+//
+// The body is skipped when it is nil or the status is 204 No Content. A zero
+// status means 200 OK, while ones outside of 200-599 are passed to OnError.
+func (m *Mux) HandleJSON(method, path string, fn func(*http.Request) (int, any, error)) {
+	if fn == nil {
+		panic("handler must not be nil")
+	}
+
+	m.Handle(method, path, func(w http.ResponseWriter, r *http.Request) error {
+		code, body, err := fn(r)
+		switch {
+		case err != nil:
+			return err
+		case code == 0:
+			code = http.StatusOK
+		case code < 200 || code > 599:
+			return fmt.Errorf("invalid status code %d", code)
+		}
+
+		if body == nil || code == http.StatusNoContent {
+			w.WriteHeader(code)
+			return nil
+		}
+
+		return writeJSON(w, code, body)
+	})
+}
+
+// Handler adapts fn into a HandlerFunc writing its result as a JSON response
+// with 200 OK, or 204 No Content if the result is a nil pointer. Errors are
+// passed to OnError as with any other HandlerFunc:
+//
+//	mux.GET("/users/{id}", httx.Handler(func(r *http.Request) (*User, error) {
+//		return store.User(r.Context(), r.PathValue("id"))
+//	}))
+func Handler[Out any](fn func(*http.Request) (Out, error)) HandlerFunc {
+	if fn == nil {
+		panic("handler must not be nil")
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) error {
+		out, err := fn(r)
+		if err != nil {
+			return err
+		}
+
+		if v := reflect.ValueOf(out); !v.IsValid() || v.Kind() == reflect.Pointer && v.IsNil() {
+			w.WriteHeader(http.StatusNoContent)
+			return nil
+		}
+
+		return writeJSON(w, http.StatusOK, out)
+	}
+}
+
+// Validator is implemented by request types validating themselves after
+// being decoded by Bind.
+type Validator interface {
+	Validate() error
+}
+
+// Bind decodes the JSON body of the request into v, then calls its Validate
+// method if it implements Validator. Malformed bodies and validation errors
+// are returned as a 400 *HTTPError, so that handlers can return them as is:
+//
+//	var req CreateUser
+//	if err := httx.Bind(r, &req); err != nil {
+//		return err
+//	}
+func Bind(r *http.Request, v any) error {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		return &HTTPError{Code: http.StatusBadRequest, Err: fmt.Errorf("invalid JSON body: %w", err)}
+	}
+
+	if validator, ok := v.(Validator); ok {
+		if err := validator.Validate(); err != nil {
+			return &HTTPError{Code: http.StatusBadRequest, Err: err}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,254 @@
+package httx
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandleJSON(t *testing.T) {
+	var handledErr error
+
+	r := NewMux()
+	r.OnError = func(w http.ResponseWriter, r *http.Request, err error) {
+		handledErr = err
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+
+	r.HandleJSON(http.MethodPost, "/users", func(*http.Request) (int, any, error) {
+		return http.StatusCreated, map[string]string{"name": "gopher"}, nil
+	})
+	r.HandleJSON(http.MethodDelete, "/users/{id}", func(*http.Request) (int, any, error) {
+		return http.StatusNoContent, nil, nil
+	})
+
+	errBoom := errors.New("boom")
+	r.HandleJSON(http.MethodGet, "/error", func(*http.Request) (int, any, error) {
+		return http.StatusOK, "unused", errBoom
+	})
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/users", nil))
+	if rec.Code != http.StatusCreated {
+		t.Errorf("status == %d, want %d", rec.Code, http.StatusCreated)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type == %q, want %q", ct, "application/json")
+	}
+	if body := rec.Body.String(); body != "{\"name\":\"gopher\"}\n" {
+		t.Errorf("body == %q", body)
+	}
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/users/1", nil))
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("status == %d, want %d", rec.Code, http.StatusNoContent)
+	}
+	if rec.Body.Len() != 0 {
+		t.Errorf("body == %q, want empty", rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/error", nil))
+	if handledErr != errBoom {
+		t.Errorf("OnError got %v, want %v", handledErr, errBoom)
+	}
+	if rec.Code != http.StatusInternalServerError || rec.Body.Len() != 0 {
+		t.Errorf("status == %d, body == %q", rec.Code, rec.Body.String())
+	}
+}
+
+func TestHandleJSONStatus(t *testing.T) {
+	var handledErr error
+
+	r := NewMux()
+	r.OnError = func(w http.ResponseWriter, r *http.Request, err error) {
+		handledErr = err
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+
+	for _, test := range []struct {
+		code int
+		body any
+		want int
+		err  bool
+	}{
+		{0, map[string]int{"n": 1}, http.StatusOK, false},
+		{0, nil, http.StatusOK, false},
+		{http.StatusContinue, nil, http.StatusInternalServerError, true},
+		{600, "body", http.StatusInternalServerError, true},
+		{-1, nil, http.StatusInternalServerError, true},
+	} {
+		r.HandleJSON(http.MethodGet, fmt.Sprintf("/status/%d/%t", test.code, test.body == nil), func(*http.Request) (int, any, error) {
+			return test.code, test.body, nil
+		})
+
+		handledErr = nil
+		rec := r.TestRequest(http.MethodGet, fmt.Sprintf("/status/%d/%t", test.code, test.body == nil), nil)
+		if rec.Code != test.want || (handledErr != nil) != test.err {
+			t.Errorf("code %d with body %v: got %d and error %v, want %d", test.code, test.body, rec.Code, handledErr, test.want)
+		}
+	}
+}
+
+func TestJSONMethodNotAllowed(t *testing.T) {
+	r := NewMux()
+	r.OnMethodNotAllowed = JSONMethodNotAllowed
+	r.GET("/path", func(http.ResponseWriter, *http.Request) error { return nil })
+	r.POST("/path", func(http.ResponseWriter, *http.Request) error { return nil })
+
+	rec := r.TestRequest(http.MethodDelete, "/path", nil)
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("status == %d, want %d", rec.Code, http.StatusMethodNotAllowed)
+	}
+	if allow := strings.Join(rec.Header().Values("Allow"), ", "); allow != "GET, OPTIONS, POST" {
+		t.Errorf("Allow == %q, want %q", allow, "GET, OPTIONS, POST")
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type == %q, want %q", ct, "application/json")
+	}
+
+	want := `{"error":"method not allowed","allowed":["GET","OPTIONS","POST"]}` + "\n"
+	if body := rec.Body.String(); body != want {
+		t.Errorf("body == %q, want %q", body, want)
+	}
+}
+
+func TestJSONNotFound(t *testing.T) {
+	r := NewMux()
+	r.OnNotFound = JSONNotFound
+
+	rec := r.TestRequest(http.MethodGet, "/nope", nil)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("status == %d, want %d", rec.Code, http.StatusNotFound)
+	}
+
+	want := `{"error":"not found"}` + "\n"
+	if body := rec.Body.String(); body != want {
+		t.Errorf("body == %q, want %q", body, want)
+	}
+}
+
+func TestDefaultJSONErrorHandler(t *testing.T) {
+	r := NewMux()
+	r.OnError = DefaultJSONErrorHandler
+	r.Pre(RequestID())
+	r.GET("/forbidden", func(w http.ResponseWriter, r *http.Request) error {
+		return Abort(http.StatusForbidden, "no access")
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/forbidden", nil)
+	req.Header.Set("X-Request-ID", "req-42")
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("status == %d, want %d", rec.Code, http.StatusForbidden)
+	}
+	want := `{"error":"no access","request_id":"req-42"}` + "\n"
+	if body := rec.Body.String(); body != want {
+		t.Errorf("body == %q, want %q", body, want)
+	}
+
+	// without the middleware
+	rec = httptest.NewRecorder()
+	DefaultJSONErrorHandler(rec, httptest.NewRequest(http.MethodGet, "/", nil), errors.New("boom"))
+	if want := `{"error":"Internal Server Error"}` + "\n"; rec.Code != http.StatusInternalServerError || rec.Body.String() != want {
+		t.Errorf("got %d %q, want 500 %q", rec.Code, rec.Body.String(), want)
+	}
+}
+
+type createUser struct {
+	Name string `json:"name"`
+}
+
+func (u *createUser) Validate() error {
+	if u.Name == "" {
+		return errors.New("name is required")
+	}
+	return nil
+}
+
+func TestBind(t *testing.T) {
+	r := NewMux()
+
+	var reached bool
+	r.POST("/users", func(w http.ResponseWriter, r *http.Request) error {
+		var req createUser
+		if err := Bind(r, &req); err != nil {
+			return err
+		}
+		reached = true
+		return writeJSON(w, http.StatusCreated, req)
+	})
+
+	tests := []struct {
+		body    string
+		code    int
+		reached bool
+		message string
+	}{
+		{`{"name":"gopher"}`, http.StatusCreated, true, `{"name":"gopher"}`},
+		{`{"name":""}`, http.StatusBadRequest, false, "name is required"},
+		{`{"name":`, http.StatusBadRequest, false, "invalid JSON body"},
+	}
+
+	for _, test := range tests {
+		reached = false
+		rec := r.TestRequest(http.MethodPost, "/users", strings.NewReader(test.body))
+
+		if rec.Code != test.code || reached != test.reached {
+			t.Errorf("POST %s: got %d, handler reached %v, want %d, %v", test.body, rec.Code, reached, test.code, test.reached)
+		}
+		if !strings.Contains(rec.Body.String(), test.message) {
+			t.Errorf("POST %s: got body %q, want it to contain %q", test.body, rec.Body.String(), test.message)
+		}
+	}
+}
+
+func TestHandler(t *testing.T) {
+	type user struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	}
+	users := map[string]*user{"1": {"1", "gopher"}}
+
+	r := NewMux()
+	r.GET("/users/{id}", Handler(func(r *http.Request) (*user, error) {
+		u, ok := users[r.PathValue("id")]
+		if !ok {
+			return nil, Abort(http.StatusNotFound, "no such user")
+		}
+		return u, nil
+	}))
+	r.GET("/count", Handler(func(r *http.Request) (int, error) {
+		return len(users), nil
+	}))
+	r.DELETE("/users/{id}", Handler(func(r *http.Request) (*user, error) {
+		return nil, nil
+	}))
+
+	tests := []struct {
+		method, path string
+		code         int
+		body         string
+	}{
+		{http.MethodGet, "/users/1", http.StatusOK, `{"id":"1","name":"gopher"}` + "\n"},
+		{http.MethodGet, "/users/2", http.StatusNotFound, "no such user\n"},
+		{http.MethodGet, "/count", http.StatusOK, "1\n"},
+		{http.MethodDelete, "/users/1", http.StatusNoContent, ""},
+	}
+
+	for _, test := range tests {
+		rec := r.TestRequest(test.method, test.path, nil)
+		if rec.Code != test.code || rec.Body.String() != test.body {
+			t.Errorf("%s %s: got %d %q, want %d %q", test.method, test.path, rec.Code, rec.Body.String(), test.code, test.body)
+		}
+		if test.code == http.StatusOK && rec.Header().Get("Content-Type") != "application/json" {
+			t.Errorf("%s %s: Content-Type == %q, want %q", test.method, test.path, rec.Header().Get("Content-Type"), "application/json")
+		}
+	}
+}
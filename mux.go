@@ -1,19 +1,47 @@
 package httx
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"log/slog"
+	"maps"
+	"net"
 	"net/http"
 	"net/url"
+	pathpkg "path"
+	"regexp"
 	"slices"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 	"unsafe"
 
 	"github.com/sirkostya009/httx/radix"
 )
 
+// DefaultErrorHandler responds with the status code of err and its message,
+// unless err is no *HTTPError of a 4xx code and Mux.ExposeErrors is disabled,
+// in which case the status text is written instead. Errors of 5xx codes are
+// logged.
 func DefaultErrorHandler(w http.ResponseWriter, r *http.Request, err error) {
-	slog.Error("error", "method", r.Method, "uri", r.RequestURI, "error", err)
-	http.Error(w, err.Error(), 500)
+	code := statusOf(err)
+	if code >= 500 {
+		slog.Error("error", "method", r.Method, "uri", r.RequestURI, "error", err)
+	}
+	http.Error(w, errorMessage(w, err, code), code)
+}
+
+// errorMessage returns the message of err to respond with, hiding the ones
+// not meant for clients unless the Mux exposes errors. Only client errors are
+// meant for them, as server ones may wrap internal errors, e.g. of files.
+func errorMessage(w http.ResponseWriter, err error, code int) string {
+	var httpErr *HTTPError
+	if rw, ok := AsResponseWriter(w); errors.As(err, &httpErr) && code < 500 || ok && rw.exposeErrors {
+		return err.Error()
+	}
+	return http.StatusText(code)
 }
 
 func DefaultOnMethodNotAllowed(w http.ResponseWriter, r *http.Request) {
@@ -26,7 +54,14 @@ func DefaultOnNotFound(w http.ResponseWriter, r *http.Request) {
 
 func DefaultOnPanic(w http.ResponseWriter, r *http.Request, a any) {
 	slog.Error("panic", "method", r.Method, "uri", r.RequestURI, "panic", a)
-	w.WriteHeader(500)
+	// the response may be already underway
+	if rw, ok := AsResponseWriter(w); !ok || !rw.Written() {
+		w.WriteHeader(500)
+	}
+}
+
+func DefaultOnBackgroundPanic(a any) {
+	slog.Error("panic in background", "panic", a)
 }
 
 type HandlerFunc func(http.ResponseWriter, *http.Request) error
@@ -37,6 +72,53 @@ func (hf HandlerFunc) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// Delegate adapts h into a HandlerFunc, so that handlers can pick another
+// handler to serve the request after inspecting it:
+//
+//	return httx.Delegate(backend)(w, r)
+//
+// The returned func always returns nil, responding is left to h. Panics of h
+// are not recovered, thus reach Mux.OnPanic like those of any other handler.
+func Delegate(h http.Handler) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		h.ServeHTTP(w, r)
+		return nil
+	}
+}
+
+// StripPrefix is the HandlerFunc counterpart of http.StripPrefix, serving
+// requests with the prefix removed from the path with h. Requests whose path
+// doesn't start with the prefix get a 404 Not Found *HTTPError instead.
+func StripPrefix(prefix string, h HandlerFunc) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		p := strings.TrimPrefix(r.URL.Path, prefix)
+		rp := strings.TrimPrefix(r.URL.RawPath, prefix)
+		if len(p) == len(r.URL.Path) || r.URL.RawPath != "" && len(rp) == len(r.URL.RawPath) {
+			return &HTTPError{Code: http.StatusNotFound}
+		}
+
+		r2 := new(http.Request)
+		*r2 = *r
+		r2.URL = new(url.URL)
+		*r2.URL = *r.URL
+		r2.URL.Path = p
+		r2.URL.RawPath = rp
+		return h(w, r2)
+	}
+}
+
+// AllowOrder is the order of methods in the Allow header.
+type AllowOrder int
+
+const (
+	// AllowSorted lists methods alphabetically.
+	AllowSorted AllowOrder = iota
+
+	// AllowDeclaration lists methods in the order their first routes were
+	// registered in, with OPTIONS last.
+	AllowDeclaration
+)
+
 type Mux struct {
 	// Centralized error handling for the Mux, invoked any time an error is
 	// returned by HandlerFunc.
@@ -51,6 +133,9 @@ type Mux struct {
 	///
 	// The "Allow" header with allowed request methods is set before this handler
 	// is called.
+	//
+	// Requests of methods no route is registered for, e.g. custom ones, get
+	// it as well if the path matches a route of another method.
 	OnMethodNotAllowed func(http.ResponseWriter, *http.Request)
 
 	// Configurable http.Handler which is called when no matching route is
@@ -65,18 +150,54 @@ type Mux struct {
 	// unrecovered panics.
 	OnPanic func(http.ResponseWriter, *http.Request, any)
 
+	// Function to handle panics recovered from goroutines started with Go,
+	// which have no request to respond to.
+	OnBackgroundPanic func(any)
+
 	// An optional http.HandlerFunc that is called on automatic OPTIONS requests.
 	// The handler is only called if its not nil and no OPTIONS
-	// handler for the specific path was set.
+	// handler for the specific path was set. Such a handler only disables
+	// the automatic response for the paths it matches, params included.
 	//
 	// The "Allowed" header is set before calling the handler.
 	GlobalOPTIONS func(http.ResponseWriter, *http.Request)
 
-	mw                 []func(HandlerFunc) HandlerFunc
+	// An optional handler for asterisk-form "OPTIONS *" requests, querying
+	// the capabilities of the server as a whole rather than of a path. If
+	// nil, they are handled like other OPTIONS requests are.
+	//
+	// The "Allow" header with the methods of all routes is set before calling
+	// the handler.
+	OnAsteriskOptions func(http.ResponseWriter, *http.Request)
+
+	// Called before redirecting a request due to RedirectTrailingSlash or
+	// RedirectResolvedPath, e.g. to log clients requesting unclean paths.
+	OnRedirect func(w http.ResponseWriter, r *http.Request, to string, code int)
+
+	// Called once a route is registered, e.g. to build a catalog of routes
+	// or to enforce naming conventions at startup. Paths with optional
+	// params are reported once per expanded path.
+	OnRegister func(method, path string)
+
+	// If set, the automatic OPTIONS responses also answer CORS preflight
+	// requests, allowing the same methods as the Allow header does.
+	CORS *CORSOptions
+
+	mw                 []middleware
+	use                []func(HandlerFunc) HandlerFunc
+	fallback           HandlerFunc
 	trees              []*radix.Tree
+	wildTree           *radix.Tree
+	statics            map[string]map[string]HandlerFunc
+	scopes             *radix.Tree
 	customMethodsIndex map[string]int
 	registeredPaths    map[string][]string
+	hostRoutes         map[string]*hostRoute
+	tags               map[string]map[string]string
+	methods            []string
+	routes             []Route
 	globalAllowed      []string
+	inFlight           *int64
 	treeMutable        bool
 
 	// Enables automatic redirection if the current route can't be matched but a
@@ -85,6 +206,10 @@ type Mux struct {
 	// For example if /foo/ is requested but a route only exists for /foo, the
 	// client is redirected to /foo with http status code 301 for GET requests
 	// and 308 for all other request methods.
+	//
+	// Registering both /foo and /foo/ is not ambiguous, each of them is
+	// served by its own handler, as the redirect only happens when the
+	// requested path can't be matched at all.
 	RedirectTrailingSlash bool
 
 	// If enabled, the router tries to resolve the current request path relative to /,
@@ -98,6 +223,104 @@ type Mux struct {
 	//
 	// RedirectTrailingSlash is independent of this option.
 	RedirectResolvedPath bool
+
+	// If non-zero, the status code of the redirects of RedirectResolvedPath
+	// and CleanPath regardless of the method, e.g. 307 Temporary Redirect or
+	// 308 Permanent Redirect to never have clients change it. Trailing slash
+	// redirects are unaffected.
+	FixedPathRedirectCode int
+
+	// If enabled, request paths are cleaned before routing, collapsing
+	// repeated slashes and resolving "." and ".." segments, so that e.g.
+	// //api//users matches /api/users. The trailing slash is kept.
+	//
+	// If RedirectResolvedPath is enabled too, unclean paths are redirected
+	// to the cleaned ones like it does instead, whether they match or not.
+	CleanPath bool
+
+	// If enabled, routes are registered both with and without the trailing
+	// slash, so that /foo and /foo/ are served by the same handler without
+	// redirecting. Catch-all routes are registered as is.
+	//
+	// Must be set before registering routes. Routes registered explicitly for
+	// both forms keep their own handlers.
+	MergeTrailingSlash bool
+
+	// The order of methods in the Allow header, AllowSorted by default. If
+	// AllowHeaderHideHEAD is enabled, HEAD is left out of it.
+	//
+	// Must be set before registering routes, as the server-wide list is
+	// built along with them.
+	AllowHeaderOrder    AllowOrder
+	AllowHeaderHideHEAD bool
+
+	// If enabled, OnNotFound, OnMethodNotAllowed and GlobalOPTIONS are run
+	// through the middleware registered with Pre, so that e.g. logging
+	// middleware sees unrouted requests too.
+	//
+	// Disabled by default, since the chain is built on every such request.
+	MiddlewareOnNotFound bool
+
+	// If enabled, request methods are upper-cased before routing, so that
+	// e.g. "get" is routed to GET handlers. The request passed to ServeHTTP
+	// is left as is, handlers getting a copy.
+	//
+	// Disabled by default, since methods are case-sensitive per RFC 9110.
+	NormalizeMethod bool
+
+	// If non-zero, requests beyond this many being served at once are
+	// responded with 503 Service Unavailable and a Retry-After of one
+	// second, rather than queueing up.
+	MaxConcurrent int
+
+	// If set, responses that may have a body get it as their Content-Type
+	// when their header is written without one, e.g. "application/json" for
+	// APIs. Handlers may still set their own.
+	DefaultContentType string
+
+	// If enabled, DefaultErrorHandler responds with the message of any
+	// error, e.g. for debugging. Otherwise, only messages of *HTTPError with
+	// 4xx codes are revealed, others are replaced with the status text, so as
+	// not to leak internals.
+	ExposeErrors bool
+
+	// If enabled, the file serving routes respond with a "foo.js.br" or
+	// "foo.js.gz" sibling of the requested "foo.js" as is, along with the
+	// matching Content-Encoding, if the client accepts it. Brotli is
+	// preferred over gzip.
+	ServePrecompressed bool
+
+	// If enabled, the handler returned by RoutesHandler doesn't reveal the
+	// routes, e.g. in production.
+	DisableRoutesHandler bool
+
+	// If enabled, routes registered with ANY are tried before the ones of
+	// the request method, e.g. for a maintenance mode handler to override
+	// every route.
+	//
+	// The 405 responses are unaffected, as a path matching an ANY route never
+	// gets one regardless of the order.
+	WildFirst bool
+
+	// Limits of the length of the request path and the number of its
+	// segments, bounding the cost of matching adversarial requests. Requests
+	// exceeding either are responded with 414 URI Too Long before routing.
+	//
+	// Zero means unlimited, which is the default.
+	MaxPathLength   int
+	MaxPathSegments int
+
+	// If non-zero, the context of every request gets a deadline this far
+	// ahead before routing. Handlers are expected to give up once it's
+	// exceeded, which is responded with 503 Service Unavailable, unless
+	// a response was already written. Retry-After is set to the timeout
+	// in that case.
+	RequestTimeout time.Duration
+
+	// Maximum number of bytes of multipart forms parsed by FormFile kept in
+	// memory, the rest being stored in temporary files. Zero means 32 MiB,
+	// as with http.Request.FormFile.
+	MaxMultipartMemory int64
 }
 
 func NewMux() *Mux {
@@ -105,26 +328,286 @@ func NewMux() *Mux {
 		trees:                 make([]*radix.Tree, 10),
 		customMethodsIndex:    map[string]int{},
 		registeredPaths:       map[string][]string{},
+		inFlight:              new(int64),
 		RedirectTrailingSlash: true,
 		RedirectResolvedPath:  true,
 		OnError:               DefaultErrorHandler,
 		OnMethodNotAllowed:    DefaultOnMethodNotAllowed,
 		OnNotFound:            DefaultOnNotFound,
 		OnPanic:               DefaultOnPanic,
+		OnBackgroundPanic:     DefaultOnBackgroundPanic,
 		GlobalOPTIONS:         func(w http.ResponseWriter, r *http.Request) {},
 	}
 }
 
+// Clone returns a copy of the Mux, to which routes and middleware can be
+// added without affecting the original.
+func (m *Mux) Clone() *Mux {
+	c := *m
+
+	c.trees = make([]*radix.Tree, len(m.trees))
+	for i, tree := range m.trees {
+		if tree != nil {
+			c.trees[i] = tree.Clone()
+		}
+	}
+	c.wildTree = c.trees[m.methodIndexOf(MethodWild)]
+	if m.scopes != nil {
+		c.scopes = m.scopes.Clone()
+	}
+
+	c.statics = make(map[string]map[string]HandlerFunc, len(m.statics))
+	for method, statics := range m.statics {
+		c.statics[method] = maps.Clone(statics)
+	}
+	c.customMethodsIndex = maps.Clone(m.customMethodsIndex)
+	c.registeredPaths = make(map[string][]string, len(m.registeredPaths))
+	for method, paths := range m.registeredPaths {
+		c.registeredPaths[method] = slices.Clone(paths)
+	}
+	c.tags = maps.Clone(m.tags)
+	c.methods = slices.Clone(m.methods)
+	c.routes = slices.Clone(m.routes)
+	c.globalAllowed = slices.Clone(m.globalAllowed)
+	c.mw = slices.Clip(m.mw)
+	c.use = slices.Clip(m.use)
+	c.inFlight = new(int64)
+	c.cloneHostRoutes(m.hostRoutes)
+
+	return &c
+}
+
+// Reset removes every route, as well as the handlers of groups, keeping the
+// middleware and the configuration, e.g. to reload routes without rewiring
+// the Mux. Not safe for use while serving requests.
+func (m *Mux) Reset() {
+	m.trees = make([]*radix.Tree, 10)
+	m.wildTree = nil
+	m.statics = nil
+	m.scopes = nil
+	m.customMethodsIndex = map[string]int{}
+	m.registeredPaths = map[string][]string{}
+	m.hostRoutes = nil
+	m.tags = nil
+	m.methods = nil
+	m.routes = nil
+	m.globalAllowed = nil
+}
+
 func (m *Mux) Group(prefix string) *Group {
 	if !strings.HasPrefix(prefix, "/") {
 		panic(`group prefix must begin with "/"`)
 	}
-	return &Group{prefix, m}
+	return &Group{prefix: prefix, m: m}
 }
 
 func (m *Mux) Pre(mw ...func(HandlerFunc) HandlerFunc) {
+	for _, fn := range mw {
+		m.mw = append(m.mw, middleware{fn: fn})
+	}
 	// clipping ensures we don't modify the original mw array in Merge
-	m.mw = slices.Clip(append(m.mw, mw...))
+	m.mw = slices.Clip(m.mw)
+}
+
+// PreMethod adds middleware like Pre does, but only for routes registered
+// afterwards for the given method, e.g. to protect state-changing ones only.
+// Routes registered for ANY and the Fallback handler are not affected, while
+// unmatched requests of the method are, if MiddlewareOnNotFound is set.
+func (m *Mux) PreMethod(method string, mw ...func(HandlerFunc) HandlerFunc) {
+	switch {
+	case len(method) == 0:
+		panic("method must not be empty")
+	case !isToken(method):
+		panic("method '" + method + "' contains invalid characters")
+	}
+
+	for _, fn := range mw {
+		m.mw = append(m.mw, middleware{method: method, fn: fn})
+	}
+	m.mw = slices.Clip(m.mw)
+}
+
+// PreNamed adds the middleware like Pre does, under a name reported by
+// MiddlewareNames.
+func (m *Mux) PreNamed(name string, mw func(HandlerFunc) HandlerFunc) {
+	m.mw = slices.Clip(append(m.mw, middleware{name: name, fn: mw}))
+}
+
+// MiddlewareCount returns the number of middleware added by Pre and its
+// variants.
+func (m *Mux) MiddlewareCount() int {
+	return len(m.mw)
+}
+
+// MiddlewareNames returns the names of the middleware added by Pre and its
+// variants in the order they were added, empty for ones added without one.
+func (m *Mux) MiddlewareNames() []string {
+	names := make([]string, len(m.mw))
+	for i, mw := range m.mw {
+		names[i] = mw.name
+	}
+	return names
+}
+
+// middleware is added by Pre, applying to handlers of the method only if set
+type middleware struct {
+	name   string
+	method string
+	fn     func(HandlerFunc) HandlerFunc
+}
+
+// wrap applies the middleware added by Pre to the handler of the method
+func (m *Mux) wrap(method string, handler HandlerFunc) HandlerFunc {
+	for _, mw := range m.mw {
+		if mw.method == "" || mw.method == method {
+			handler = mw.fn(handler)
+		}
+	}
+	return handler
+}
+
+// Use adds middleware applied at request time to whichever handler matched,
+// so unlike Pre it affects routes registered before the call as well. The
+// wrapping happens on every request, thus Pre is cheaper when declaration
+// order is not an issue.
+func (m *Mux) Use(mw ...func(HandlerFunc) HandlerFunc) {
+	m.use = slices.Clip(append(m.use, mw...))
+}
+
+// Fallback sets the handler serving requests no route matches, taking
+// precedence over OnNotFound. Unlike the latter, it's run through the
+// middleware added so far and its error is passed to OnError.
+//
+// Groups with their own OnNotFound handler keep using it.
+func (m *Mux) Fallback(handler HandlerFunc) {
+	if handler == nil {
+		panic("handler must not be nil")
+	}
+
+	m.fallback = m.wrap("", handler)
+}
+
+// Chain passes requests no route matches on to the handlers in order. A
+// *Mux passes them on in place of calling its OnNotFound, while other
+// handlers do so by responding with 404 Not Found, their response being
+// discarded then. The handlers themselves are left as is, thus may be served
+// elsewhere too.
+//
+// Chain sets OnNotFound, thus a Fallback handler or groups with their own
+// OnNotFound take precedence. Requests matching a route of another method
+// get 405 without being passed on, unless OnMethodNotAllowed is nil.
+//
+//	api.Chain(legacy, http.FileServer(http.Dir("public")))
+func (m *Mux) Chain(next ...http.Handler) {
+	if len(next) == 0 {
+		return
+	}
+
+	for _, h := range next {
+		if h == nil {
+			panic("chained handler must not be nil")
+		}
+	}
+
+	m.OnNotFound = chainOf(next)
+}
+
+// chainOf returns a handler serving requests with the first of the handlers,
+// passing the ones it finds nothing for on to the rest
+func chainOf(handlers []http.Handler) func(http.ResponseWriter, *http.Request) {
+	h := handlers[0]
+	if len(handlers) == 1 {
+		return h.ServeHTTP
+	}
+	rest := chainOf(handlers[1:])
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		// the mux finds the chain in the writer once it finds no route
+		if sub, ok := h.(*Mux); ok {
+			if rw, ok := AsResponseWriter(w); ok {
+				chainMux, chainNext := rw.chainMux, rw.chainNext
+				rw.chainMux, rw.chainNext = sub, rest
+				defer func() { rw.chainMux, rw.chainNext = chainMux, chainNext }()
+
+				sub.ServeHTTP(w, r)
+				return
+			}
+		}
+
+		header := w.Header().Clone()
+		cw := &chainWriter{ResponseWriter: w}
+		h.ServeHTTP(cw, r)
+		if cw.notFound {
+			clear(w.Header())
+			maps.Copy(w.Header(), header)
+			rest(w, r)
+		}
+	}
+}
+
+// chainWriter discards the response of a chained handler responding with
+// 404 Not Found
+type chainWriter struct {
+	http.ResponseWriter
+	wroteHeader bool
+	notFound    bool
+}
+
+func (cw *chainWriter) WriteHeader(code int) {
+	if cw.wroteHeader {
+		return
+	}
+	cw.wroteHeader = true
+
+	if code == http.StatusNotFound {
+		cw.notFound = true
+		return
+	}
+	cw.ResponseWriter.WriteHeader(code)
+}
+
+func (cw *chainWriter) Write(b []byte) (int, error) {
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+	if cw.notFound {
+		return len(b), nil
+	}
+	return cw.ResponseWriter.Write(b)
+}
+
+func (cw *chainWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+// Go runs fn in a new goroutine, passing its panics to OnBackgroundPanic
+// rather than crashing the process, as OnPanic only covers the goroutines
+// serving requests.
+func (m *Mux) Go(fn func()) {
+	onPanic := m.OnBackgroundPanic
+	go func() {
+		if onPanic != nil {
+			defer func() {
+				if recv := recover(); recv != nil {
+					onPanic(recv)
+				}
+			}()
+		}
+		fn()
+	}()
+}
+
+// Mutable allows updating the route handler, which is disabled by default.
+//
+// WARNING: Use with care. It could generate unexpected behaviours
+func (m *Mux) Mutable(v bool) {
+	m.treeMutable = v
+
+	for _, tree := range m.trees {
+		if tree != nil {
+			tree.Mutable = v
+		}
+	}
 }
 
 // List returns all registered routes grouped by method
@@ -132,6 +615,128 @@ func (m *Mux) List() map[string][]string {
 	return m.registeredPaths
 }
 
+// Route is a registered route, as passed to Handle
+type Route struct {
+	Method string `json:"method"`
+	Path   string `json:"path"`
+}
+
+// ListOrdered returns all registered routes in the order they were added.
+// Paths with optional params are reported as registered, not expanded.
+func (m *Mux) ListOrdered() []Route {
+	return slices.Clone(m.routes)
+}
+
+// Routes returns the routes stored in the trees, for each method in the
+// order they are tried when matching. Paths expanded from optional params
+// or added as trailing slash variants are reported as registered, once.
+func (m *Mux) Routes() []Route {
+	methods := make([]string, 0, len(m.registeredPaths))
+	for method := range m.registeredPaths {
+		methods = append(methods, method)
+	}
+	slices.SortFunc(methods, func(a, b string) int {
+		return m.methodIndexOf(a) - m.methodIndexOf(b)
+	})
+
+	var routes []Route
+	for _, method := range methods {
+		for _, path := range m.trees[m.methodIndexOf(method)].Routes() {
+			routes = append(routes, Route{method, path})
+		}
+	}
+
+	return routes
+}
+
+// HandleTagged registers the handler like Handle, attaching the tags to the
+// route, e.g. a summary for documentation or an auth policy. The tags are
+// reported by RouteTags, as well as by the handler of RoutesHandler.
+func (m *Mux) HandleTagged(method, path string, handler HandlerFunc, tags map[string]string) {
+	m.Handle(method, path, handler)
+
+	if len(tags) > 0 {
+		if m.tags == nil {
+			m.tags = map[string]map[string]string{}
+		}
+		m.tags[method+" "+path] = maps.Clone(tags)
+	}
+}
+
+// RouteTags returns a copy of the tags of the route registered with
+// HandleTagged for the method and path, as registered, or nil.
+func (m *Mux) RouteTags(method, path string) map[string]string {
+	return maps.Clone(m.tags[method+" "+path])
+}
+
+// RoutesHandler returns a handler writing the routes reported by Routes as a
+// JSON array, e.g. to be registered at "/debug/routes" in development, along
+// with their tags, if any. It responds as if there was no route while
+// DisableRoutesHandler is set.
+func (m *Mux) RoutesHandler() HandlerFunc {
+	type taggedRoute struct {
+		Route
+		Tags map[string]string `json:"tags,omitempty"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) error {
+		m := m.serving(w)
+		if m.DisableRoutesHandler {
+			m.serveNotFound(w, r)
+			return nil
+		}
+
+		routes := []taggedRoute{}
+		for _, route := range m.Routes() {
+			routes = append(routes, taggedRoute{route, m.tags[route.Method+" "+route.Path]})
+		}
+		return writeJSON(w, http.StatusOK, routes)
+	}
+}
+
+// Stats returns the statistics of the tree of every method routes are
+// registered for, see radix.Tree.Stats.
+func (m *Mux) Stats() map[string]radix.TreeStats {
+	stats := make(map[string]radix.TreeStats, len(m.registeredPaths))
+	for method := range m.registeredPaths {
+		stats[method] = m.trees[m.methodIndexOf(method)].Stats()
+	}
+	return stats
+}
+
+// Methods returns the sorted methods, for which the exact path pattern is
+// registered. Routes registered with ANY are reported as MethodWild.
+func (m *Mux) Methods(path string) []string {
+	var methods []string
+	for method, paths := range m.registeredPaths {
+		if slices.Contains(paths, path) {
+			methods = append(methods, method)
+		}
+	}
+	slices.Sort(methods)
+
+	return methods
+}
+
+// Validate reports routes that can never be matched, because a broader
+// param route registered under the same method is always tried first.
+func (m *Mux) Validate() []error {
+	methods := make([]string, 0, len(m.registeredPaths))
+	for method := range m.registeredPaths {
+		methods = append(methods, method)
+	}
+	slices.Sort(methods)
+
+	var errs []error
+	for _, method := range methods {
+		for _, err := range m.treeOf(method).Validate() {
+			errs = append(errs, fmt.Errorf("%s %w", method, err))
+		}
+	}
+
+	return errs
+}
+
 // GET is a shortcut for router.Handle(http.MethodGet, path, handler)
 func (m *Mux) GET(path string, handler HandlerFunc) {
 	m.Handle(http.MethodGet, path, handler)
@@ -168,6 +773,10 @@ func (m *Mux) CONNECT(path string, handler HandlerFunc) {
 }
 
 // OPTIONS is a shortcut for router.Handle(http.MethodOptions, path, handler)
+//
+// The handler replaces the automatic OPTIONS response for the paths it
+// matches only, it's up to it to set the Allow header then. Other paths keep
+// being answered by GlobalOPTIONS.
 func (m *Mux) OPTIONS(path string, handler HandlerFunc) {
 	m.Handle(http.MethodOptions, path, handler)
 }
@@ -180,11 +789,37 @@ func (m *Mux) TRACE(path string, handler HandlerFunc) {
 // ANY is a shortcut for router.Handle(router.MethodWild, path, handler)
 //
 // Requests with any method will route to this, unless a route with a distinct method was found.
+// For OPTIONS requests, the Allow header listing every standard method is set
+// before calling the handler.
 func (m *Mux) ANY(path string, handler HandlerFunc) {
 	m.Handle(MethodWild, path, handler)
 }
 
 func (m *Mux) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// nested muxes reuse the writer of the outer one
+	if rw, ok := w.(*ResponseWriter); ok {
+		if rw.contentType == "" {
+			rw.contentType = m.DefaultContentType
+		}
+		rw.exposeErrors = rw.exposeErrors || m.ExposeErrors
+
+		outer := rw.mux
+		rw.mux = m
+		defer func() { rw.mux = outer }()
+	} else {
+		rw := responseWriterPool.Get().(*ResponseWriter)
+		*rw = ResponseWriter{ResponseWriter: w, contentType: m.DefaultContentType, exposeErrors: m.ExposeErrors, mux: m}
+		// a chain is passed on by writers wrapping the outer one
+		if outer, ok := AsResponseWriter(w); ok {
+			rw.chainMux, rw.chainNext = outer.chainMux, outer.chainNext
+		}
+		defer func() {
+			*rw = ResponseWriter{}
+			responseWriterPool.Put(rw)
+		}()
+		w = rw
+	}
+
 	if m.OnPanic != nil {
 		defer func() {
 			if recv := recover(); recv != nil {
@@ -193,33 +828,117 @@ func (m *Mux) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		}()
 	}
 
+	if m.MaxConcurrent > 0 {
+		defer atomic.AddInt64(m.inFlight, -1)
+		if atomic.AddInt64(m.inFlight, 1) > int64(m.MaxConcurrent) {
+			SetRetryAfter(w, time.Second)
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+	}
+
+	if m.RequestTimeout > 0 {
+		ctx, cancel := context.WithTimeout(r.Context(), m.RequestTimeout)
+		defer cancel()
+		r = r.WithContext(ctx)
+
+		defer func() {
+			if rw := w.(*ResponseWriter); ctx.Err() == context.DeadlineExceeded && !rw.Written() {
+				SetRetryAfter(rw, m.RequestTimeout)
+				rw.WriteHeader(http.StatusServiceUnavailable)
+			}
+		}()
+	}
+
+	if m.NormalizeMethod {
+		// the request is copied, as the caller's one must not be modified
+		if method := strings.ToUpper(r.Method); method != r.Method {
+			r2 := new(http.Request)
+			*r2 = *r
+			r2.Method = method
+			r = r2
+		}
+	}
+
 	path := r.URL.Path
 
-	if methodIndex := m.methodIndexOf(r.Method); methodIndex > -1 {
-		if tree := m.trees[methodIndex]; tree != nil {
-			if handler, tsr := tree.Get(path, r); handler != nil {
-				handler := handler.(HandlerFunc) // ugly cast but i cant cyclically reference httx.HandleFunc in radix package
-				err := handler(w, r)
-				if err != nil {
-					m.OnError(w, r, err)
+	if m.MaxPathLength > 0 && len(path) > m.MaxPathLength ||
+		m.MaxPathSegments > 0 && strings.Count(path, "/") > m.MaxPathSegments {
+		w.WriteHeader(http.StatusRequestURITooLong)
+		return
+	}
+
+	// net/http rejects malformed escapes when parsing requests, but the URL
+	// may have been rewritten since, e.g. by a proxy
+	if r.URL.RawPath != "" && !validEscapes(r.URL.RawPath) {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	if r.Method == http.MethodOptions && r.RequestURI == "*" && m.OnAsteriskOptions != nil {
+		w.Header()["Allow"] = m.allowed("*", http.MethodOptions)
+		m.serveFallback(w, r, m.OnAsteriskOptions)
+		return
+	}
+
+	if m.CleanPath && strings.HasPrefix(path, "/") {
+		if cleaned := cleanPath(path); cleaned != path {
+			u := *r.URL
+			u.Path = cleaned
+			if u.RawPath != "" {
+				u.RawPath = cleanPath(u.RawPath)
+			}
+
+			if m.RedirectResolvedPath && r.Method != http.MethodConnect {
+				code := http.StatusMovedPermanently
+				if m.FixedPathRedirectCode != 0 {
+					code = m.FixedPathRedirectCode
+				} else if r.Method != http.MethodGet {
+					code = http.StatusPermanentRedirect
 				}
-				return
-			} else if r.Method != http.MethodConnect && path != "/" {
-				if ok := m.tryRedirect(w, r, tree, tsr, r.Method, path); ok {
-					return
+
+				to := u.EscapedPath()
+				if u.RawQuery != "" {
+					to += "?" + u.RawQuery
 				}
+				m.redirect(w, r, to, code)
+				return
 			}
+
+			r2 := new(http.Request)
+			*r2 = *r
+			r2.URL = &u
+			r, path = r2, cleaned
 		}
 	}
 
-	// Try to search in the wild method tree
-	if tree := m.trees[m.methodIndexOf(MethodWild)]; tree != nil {
+	if m.WildFirst && m.wildTree != nil {
+		if handler, _ := m.wildTree.Get(path, r); handler != nil {
+			m.serveWild(w, r, path, handler.(HandlerFunc))
+			return
+		}
+	}
+
+	if handler, ok := m.statics[r.Method][path]; ok {
+		m.serve(w, r, handler)
+		return
+	}
+
+	if tree := m.treeOf(r.Method); tree != nil {
 		if handler, tsr := tree.Get(path, r); handler != nil {
-			handler := handler.(HandlerFunc)
-			err := handler(w, r)
-			if err != nil {
-				m.OnError(w, r, err)
+			m.serve(w, r, handler.(HandlerFunc)) // ugly cast but i cant cyclically reference httx.HandleFunc in radix package
+			return
+		} else if r.Method != http.MethodConnect && path != "/" {
+			if ok := m.tryRedirect(w, r, tree, tsr, r.Method, path); ok {
+				return
 			}
+		}
+	}
+
+	// Try to search in the wild method tree
+	if tree := m.wildTree; tree != nil {
+		if handler, tsr := tree.Get(path, r); handler != nil {
+			m.serveWild(w, r, path, handler.(HandlerFunc))
 			return
 		} else if r.Method != http.MethodConnect && path != "/" {
 			if ok := m.tryRedirect(w, r, tree, tsr, r.Method, path); ok {
@@ -228,21 +947,111 @@ func (m *Mux) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	onMethodNotAllowed := m.OnMethodNotAllowed
+	if scope := m.scopeOf(path); scope != nil && scope.onMethodNotAllowed != nil {
+		onMethodNotAllowed = scope.onMethodNotAllowed
+	}
+
 	if r.Method == http.MethodOptions && m.GlobalOPTIONS != nil {
 		if allow := m.allowed(path, http.MethodOptions); len(allow) > 0 {
 			w.Header()["Allow"] = allow
-			m.GlobalOPTIONS(w, r)
+			if m.CORS != nil {
+				m.CORS.preflight(w, r, allow)
+			}
+			m.serveFallback(w, r, m.GlobalOPTIONS)
 			return
 		}
-	} else if m.OnMethodNotAllowed != nil {
+	} else if onMethodNotAllowed != nil {
 		if allow := m.allowed(path, r.Method); len(allow) > 0 {
 			w.Header()["Allow"] = allow
-			m.OnMethodNotAllowed(w, r)
+			m.serveFallback(w, r, onMethodNotAllowed)
 			return
 		}
 	}
 
-	m.OnNotFound(w, r)
+	m.serveNotFound(w, r)
+}
+
+// serveWild serves the request with the handler of an ANY route
+func (m *Mux) serveWild(w http.ResponseWriter, r *http.Request, path string, handler HandlerFunc) {
+	if r.Method == http.MethodOptions {
+		w.Header()["Allow"] = m.allowed(path, http.MethodOptions)
+	}
+	m.serve(w, r, handler)
+}
+
+// serving returns the Mux serving the request written to w, which handlers
+// registered by m refer to rather than m itself, as they may be copied to
+// other muxes by Clone or Merge
+func (m *Mux) serving(w http.ResponseWriter) *Mux {
+	if rw, ok := AsResponseWriter(w); ok && rw.mux != nil {
+		return rw.mux
+	}
+	return m
+}
+
+// serveNotFound serves a request no route matches with the OnNotFound handler
+// of the group the path belongs to, if set, the Fallback handler or
+// Mux.OnNotFound otherwise
+func (m *Mux) serveNotFound(w http.ResponseWriter, r *http.Request) {
+	switch scope := m.scopeOf(r.URL.Path); {
+	case scope != nil && scope.onNotFound != nil:
+		m.serveFallback(w, r, scope.onNotFound)
+	case m.fallback != nil:
+		m.serve(w, r, m.fallback)
+	default:
+		onNotFound := m.OnNotFound
+		if rw, ok := AsResponseWriter(w); ok && rw.chainMux == m {
+			onNotFound = rw.chainNext
+		}
+		m.serveFallback(w, r, onNotFound)
+	}
+}
+
+// scopeOf returns the scope of the innermost group with its own handlers,
+// which the path belongs to
+func (m *Mux) scopeOf(path string) *groupScope {
+	if m.scopes == nil {
+		return nil
+	}
+
+	h, tsr := m.scopes.Get(path, nil)
+	if tsr {
+		h, _ = m.scopes.Get(path[:len(path)-1], nil)
+	}
+
+	scope, _ := h.(*groupScope)
+	return scope
+}
+
+// serveFallback calls one of the handlers used when no route matches,
+// wrapping it with middleware if MiddlewareOnNotFound is set.
+func (m *Mux) serveFallback(w http.ResponseWriter, r *http.Request, fallback func(http.ResponseWriter, *http.Request)) {
+	if !m.MiddlewareOnNotFound || len(m.mw) == 0 && len(m.use) == 0 {
+		fallback(w, r)
+		return
+	}
+
+	handler := HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		fallback(w, r)
+		return nil
+	})
+	m.serve(w, r, m.wrap(r.Method, handler))
+}
+
+// serve calls the matched handler wrapped with the middleware added by Use,
+// passing its error to OnError
+func (m *Mux) serve(w http.ResponseWriter, r *http.Request, handler HandlerFunc) {
+	for _, mw := range m.use {
+		handler = mw(handler)
+	}
+
+	if err := handler(w, r); err != nil {
+		if m.RequestTimeout > 0 && errors.Is(err, context.DeadlineExceeded) {
+			SetRetryAfter(w, m.RequestTimeout)
+		}
+		m.OnError(w, r, err)
+	}
 }
 
 var base, _ = url.Parse("/")
@@ -270,14 +1079,17 @@ func (m *Mux) tryRedirect(w http.ResponseWriter, r *http.Request, tree *radix.Tr
 			uri = append(uri, r.URL.RawQuery...)
 		}
 
-		w.Header()["Location"] = []string{unsafe.String(&uri[0], len(uri))}
-		w.WriteHeader(code)
+		m.redirect(w, r, unsafe.String(&uri[0], len(uri)), code)
 
 		return true
 	}
 
 	// Try to fix the request path
 	if m.RedirectResolvedPath {
+		if m.FixedPathRedirectCode != 0 {
+			code = m.FixedPathRedirectCode
+		}
+
 		uri := make([]byte, 0, len(r.RequestURI)+1)
 		resolved := base.ResolveReference(r.URL)
 		found := tree.FindCaseInsensitivePath(
@@ -292,8 +1104,7 @@ func (m *Mux) tryRedirect(w http.ResponseWriter, r *http.Request, tree *radix.Tr
 				uri = append(uri, r.URL.RawQuery...)
 			}
 
-			w.Header()["Location"] = []string{unsafe.String(&uri[0], len(uri))}
-			w.WriteHeader(code)
+			m.redirect(w, r, unsafe.String(&uri[0], len(uri)), code)
 
 			return true
 		}
@@ -302,9 +1113,31 @@ func (m *Mux) tryRedirect(w http.ResponseWriter, r *http.Request, tree *radix.Tr
 	return false
 }
 
+// redirect writes the redirect to the location, calling OnRedirect first
+func (m *Mux) redirect(w http.ResponseWriter, r *http.Request, to string, code int) {
+	if m.OnRedirect != nil {
+		m.OnRedirect(w, r, to, code)
+	}
+
+	w.Header()["Location"] = []string{to}
+	w.WriteHeader(code)
+}
+
+// mergeWildcard is the name of the wildcard param bare '*' merges register
+const mergeWildcard = "httx.merge"
+
+// Merge copies the routes of a *Mux under the prefix. Any other handler is
+// served at the prefix with it stripped from the path, in which case the
+// prefix must end with either a bare '*', e.g. "/fs/*", or a wildcard param,
+// e.g. "/fs/{filepath:*}", both of which are equivalent.
+//
+// A trailing '/' of a *Mux prefix is dropped, so "/api/" and "/api" are
+// equivalent.
 func (m *Mux) Merge(prefix string, handler http.Handler) {
 	switch h := handler.(type) {
 	case *Mux:
+		sub := h
+		prefix = strings.TrimSuffix(prefix, "/")
 		for method, paths := range h.registeredPaths {
 			for _, path := range paths {
 				methodIndex := h.methodIndexOf(method)
@@ -315,6 +1148,11 @@ func (m *Mux) Merge(prefix string, handler http.Handler) {
 					}
 					switch h := h.(type) {
 					case HandlerFunc:
+						// the merged mux won't serve the route, so its
+						// request-time middleware is applied right away
+						for _, mw := range sub.use {
+							h = mw(h)
+						}
 						m.Handle(method, fullPath, h)
 					default:
 						m.Merge(fullPath, h)
@@ -323,42 +1161,152 @@ func (m *Mux) Merge(prefix string, handler http.Handler) {
 			}
 		}
 	default:
-		if !strings.HasSuffix(prefix, "*") {
-			panic("non-Mux merges must end with *")
+		var noStar string
+		switch i := strings.LastIndexByte(prefix, '{'); {
+		case strings.HasSuffix(prefix, "/*"):
+			noStar = prefix[:len(prefix)-1]
+			prefix = noStar + "{" + mergeWildcard + ":*}"
+		case i > -1 && strings.HasSuffix(prefix, ":*}"):
+			noStar = prefix[:i]
+		default:
+			panic("non-Mux merges must end with '/*' or a wildcard such as '{filepath:*}' in prefix '" + prefix + "'")
 		}
-		noStar := prefix[:len(prefix)-1]
-		notFound := m.OnNotFound
+		stripped := http.StripPrefix(noStar, h)
 		m.Handle(MethodWild, prefix, func(w http.ResponseWriter, r *http.Request) error {
-			// the exact copy of code from http.StripPrefix
-			p := strings.TrimPrefix(r.URL.Path, noStar)
-			rp := strings.TrimPrefix(r.URL.RawPath, noStar)
-			if len(p) < len(r.URL.Path) && (r.URL.RawPath == "" || len(rp) < len(r.URL.RawPath)) {
-				r2 := &http.Request{}
-				*r2 = *r
-				r2.URL = &url.URL{}
-				*r2.URL = *r.URL
-				r2.URL.Path = p
-				r2.URL.RawPath = rp
-				h.ServeHTTP(w, r2)
+			// the same check http.StripPrefix does, as it would respond with
+			// http.NotFound otherwise
+			if strings.HasPrefix(r.URL.Path, noStar) && (r.URL.RawPath == "" || strings.HasPrefix(r.URL.RawPath, noStar)) {
+				stripped.ServeHTTP(w, r)
 			} else {
-				notFound(w, r)
+				m.serving(w).serveNotFound(w, r)
 			}
 			return nil
 		})
 	}
 }
 
+// mountWildcard is the name of the wildcard param routing to mounted muxes
+const mountWildcard = "httx.mount"
+
+// Mount dispatches requests to the prefix and below to sub, with the prefix
+// stripped from the path. Unlike Merge, sub is referenced rather than copied,
+// so routes added to it later are served too, along with its own middleware
+// and handlers of errors and unmatched requests.
+//
+// The prefix may contain params, whose values remain accessible with
+// PathValue. Escaped characters in the rest of the path are decoded. A
+// trailing '/' of the prefix is dropped, so a "/" prefix mounts sub at the
+// root, serving whatever no route of m matches.
+//
+// The route recorded in the ResponseWriter is the one of sub, prefixed, e.g.
+// "/orgs/{org}/users/{id}".
+func (m *Mux) Mount(prefix string, sub *Mux) {
+	if sub == nil {
+		panic("mounted mux must not be nil")
+	}
+	prefix = strings.TrimSuffix(prefix, "/")
+
+	handler := func(w http.ResponseWriter, r *http.Request) error {
+		r2 := new(http.Request)
+		*r2 = *r
+		r2.URL = new(url.URL)
+		*r2.URL = *r.URL
+		r2.URL.Path = "/" + r.PathValue(mountWildcard)
+		r2.URL.RawPath = ""
+
+		// the route of sub replaces the mount pattern
+		if rw, ok := AsResponseWriter(w); ok {
+			routePrefix := rw.routePrefix
+			rw.route, rw.routePrefix = "", routePrefix+prefix
+			defer func() { rw.routePrefix = routePrefix }()
+		}
+
+		sub.ServeHTTP(w, r2)
+		return nil
+	}
+
+	if prefix != "" {
+		m.ANY(prefix, handler)
+	}
+	m.ANY(prefix+"/{"+mountWildcard+":*}", handler)
+}
+
+// Handle registers the handler for the given method and path.
+//
+// Besides the standard methods, any valid method token is accepted, e.g.
+// WebDAV's PROPFIND or MKCOL, each getting a tree of its own. Such methods
+// are listed in the Allow header just like the standard ones.
+//
+// Paths must not contain empty segments, e.g. "/a//b". Requests with those
+// aren't routed to the route without them either, e.g. "/a/b", unless
+// CleanPath is enabled.
 func (m *Mux) Handle(method, path string, handler HandlerFunc) {
+	m.HandleMany(method, []string{path}, handler)
+}
+
+// RouteSpec describes a route for HandleAll.
+type RouteSpec struct {
+	Method, Path string
+	Handler      HandlerFunc
+
+	// Applied to the handler only, within the middleware added by Pre, the
+	// last one being the outermost as with Pre.
+	Middleware []func(HandlerFunc) HandlerFunc
+}
+
+// HandleAll registers each of the routes along with its middleware, e.g.
+// from a table or configuration.
+func (m *Mux) HandleAll(routes []RouteSpec) {
+	for _, route := range routes {
+		handler := route.Handler
+		if handler == nil {
+			panic("handler must not be nil in route " + route.Method + " " + route.Path)
+		}
+
+		for _, mw := range route.Middleware {
+			handler = mw(handler)
+		}
+		m.Handle(route.Method, route.Path, handler)
+	}
+}
+
+// HandleMany registers the handler for the given method at each of the
+// paths, e.g. aliases of a route. The handler is wrapped with middleware only
+// once, being shared by all of them.
+func (m *Mux) HandleMany(method string, paths []string, handler HandlerFunc) {
 	switch {
 	case len(method) == 0:
 		panic("method must not be empty")
+	case !isToken(method):
+		panic("method '" + method + "' contains invalid characters")
 	case handler == nil:
 		panic("handler must not be nil")
-	default:
+	}
+	for _, path := range paths {
 		validatePath(path)
 	}
 
-	m.registeredPaths[method] = append(m.registeredPaths[method], path)
+	handler = m.wrap(method, handler)
+
+	for _, path := range paths {
+		m.handle(method, path, handler)
+	}
+}
+
+// handle registers the handler, already wrapped with middleware, for the
+// given method and path
+func (m *Mux) handle(method, path string, handler HandlerFunc) {
+	if slices.Contains(m.registeredPaths[method], path) {
+		if !m.treeMutable {
+			panic("httx: duplicate route " + method + " " + path)
+		}
+	} else {
+		if len(m.registeredPaths[method]) == 0 {
+			m.methods = append(m.methods, method)
+		}
+		m.registeredPaths[method] = append(m.registeredPaths[method], path)
+		m.routes = append(m.routes, Route{method, path})
+	}
 
 	methodIndex := m.methodIndexOf(method)
 	if methodIndex == -1 {
@@ -377,65 +1325,290 @@ func (m *Mux) Handle(method, path string, handler HandlerFunc) {
 
 		m.trees[methodIndex] = tree
 		m.globalAllowed = m.allowed("*", "")
-	}
 
-	for _, mw := range m.mw {
-		handler = mw(handler)
+		if method == MethodWild {
+			m.wildTree = tree
+		}
 	}
 
-	optionalPaths := getOptionalPaths(path)
+	handler = withRoute(handler, path)
+
+	paths := getOptionalPaths(path)
 
 	// if no optional paths, adds the original
-	if len(optionalPaths) == 0 {
-		tree.Add(path, handler)
-	} else {
-		for _, p := range optionalPaths {
-			tree.Add(p, handler)
+	if len(paths) == 0 {
+		paths = []string{path}
+	}
+
+	for _, p := range paths {
+		if !m.MergeTrailingSlash {
+			m.addRoute(tree, method, p, path, handler)
+			continue
+		}
+
+		v, ok := trailingSlashVariant(p)
+		switch {
+		case !ok:
+			m.addRoute(tree, method, p, path, handler)
+		case slices.Contains(m.registeredPaths[method], v):
+			// p was added along with the explicitly registered variant,
+			// thus gets replaced
+			tree.Mutable = true
+			m.addRoute(tree, method, p, path, handler)
+			tree.Mutable = m.treeMutable
+		default:
+			m.addRoute(tree, method, p, path, handler)
+			m.addRoute(tree, method, v, path, handler)
+		}
+	}
+
+	if m.OnRegister != nil {
+		for _, p := range paths {
+			m.OnRegister(method, p)
+		}
+	}
+}
+
+// addRoute adds the path to the tree, indexing it in the statics as well if
+// it has no params, so that ServeHTTP can skip traversing the tree
+func (m *Mux) addRoute(tree *radix.Tree, method, path, pattern string, handler HandlerFunc) {
+	tree.AddRoute(path, pattern, handler)
+
+	// wild routes are only tried after the ones of the method, thus not
+	// indexed
+	if method == MethodWild || strings.IndexByte(path, '{') > -1 {
+		return
+	}
+
+	if m.statics == nil {
+		m.statics = make(map[string]map[string]HandlerFunc)
+	}
+	if m.statics[method] == nil {
+		m.statics[method] = make(map[string]HandlerFunc)
+	}
+	m.statics[method][path] = handler
+}
+
+// HandleRegex registers the handler for the given method and pattern, the
+// latter containing exactly one plain param, e.g. "/users/{id}", whose
+// value must match re for the route to match. The param becomes a regex one,
+// e.g. "/users/{id:[a-z]+}", thus the regex must match the whole value and
+// the route takes precedence over plain params at the same position.
+func (m *Mux) HandleRegex(method, pattern string, re *regexp.Regexp, handler HandlerFunc) {
+	switch {
+	case re == nil:
+		panic("regex must not be nil")
+	case handler == nil:
+		panic("handler must not be nil")
+	}
+
+	start, end := strings.IndexByte(pattern, '{'), strings.IndexByte(pattern, '}')
+	if start < 0 || end < start || strings.Count(pattern, "{") != 1 || strings.ContainsAny(pattern[start+1:end], ":?") {
+		panic("pattern '" + pattern + "' must contain exactly one plain param")
+	}
+
+	m.Handle(method, pattern[:end]+":"+re.String()+pattern[end:], handler)
+}
+
+// HandleHost registers the handler for the given method and path, serving
+// only requests for the host, e.g. for simple multi-tenant setups. The same
+// method and path may be registered for several hosts, requests for other
+// ones are served with the not found handler.
+//
+// Hosts are compared case-insensitively and without the port. The handlers
+// share the middleware added with Pre before the first registration of the
+// method and path.
+func (m *Mux) HandleHost(host, method, path string, handler HandlerFunc) {
+	if handler == nil {
+		panic("handler must not be nil")
+	}
+	host = hostname(host)
+
+	key := method + " " + path
+	route, ok := m.hostRoutes[key]
+	if !ok {
+		route = &hostRoute{hosts: map[string]HandlerFunc{}, mw: len(m.mw)}
+		m.Handle(method, path, m.dispatchHost(route))
+
+		if m.hostRoutes == nil {
+			m.hostRoutes = map[string]*hostRoute{}
 		}
+		m.hostRoutes[key] = route
+	} else if _, ok := route.hosts[host]; ok {
+		panic("httx: duplicate route " + method + " " + path + " for host " + host)
+	}
+
+	route.hosts[host] = handler
+}
+
+// hostRoute holds the handlers of a method and path registered with
+// HandleHost, along with the number of middleware added before the first one
+type hostRoute struct {
+	hosts map[string]HandlerFunc
+	mw    int
+}
+
+// dispatchHost returns the handler serving the route for the request host
+func (m *Mux) dispatchHost(route *hostRoute) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		if handler, ok := route.hosts[hostname(r.Host)]; ok {
+			return handler(w, r)
+		}
+		m.serveNotFound(w, r)
+		return nil
+	}
+}
+
+// cloneHostRoutes copies the routes of HandleHost into the clone, replacing
+// their handlers with ones dispatching to the copies
+func (m *Mux) cloneHostRoutes(routes map[string]*hostRoute) {
+	if len(routes) == 0 {
+		m.hostRoutes = nil
+		return
+	}
+
+	onRegister, treeMutable := m.OnRegister, m.treeMutable
+	m.OnRegister, m.treeMutable = nil, true
+	for _, tree := range m.trees {
+		if tree != nil {
+			tree.Mutable = true
+		}
+	}
+
+	m.hostRoutes = make(map[string]*hostRoute, len(routes))
+	for key, route := range routes {
+		c := &hostRoute{hosts: maps.Clone(route.hosts), mw: route.mw}
+		m.hostRoutes[key] = c
+
+		// the middleware as of the first registration
+		handler := m.dispatchHost(c)
+		method, path, _ := strings.Cut(key, " ")
+		for _, mw := range m.mw[:c.mw] {
+			if mw.method == "" || mw.method == method {
+				handler = mw.fn(handler)
+			}
+		}
+		m.handle(method, path, handler)
+	}
+
+	m.OnRegister, m.treeMutable = onRegister, treeMutable
+	for _, tree := range m.trees {
+		if tree != nil {
+			tree.Mutable = treeMutable
+		}
+	}
+}
+
+// hostname returns the lower-cased host without the port
+func hostname(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.ToLower(strings.TrimSuffix(strings.TrimPrefix(host, "["), "]"))
+}
+
+// HandleLazy registers a handler for the given method and path, built by
+// factory on the first matching request rather than at registration. The
+// factory is called only once, even for concurrent first requests.
+func (m *Mux) HandleLazy(method, path string, factory func() HandlerFunc) {
+	if factory == nil {
+		panic("factory must not be nil")
 	}
+
+	var (
+		once    sync.Once
+		handler HandlerFunc
+	)
+	m.Handle(method, path, func(w http.ResponseWriter, r *http.Request) error {
+		once.Do(func() {
+			handler = factory()
+		})
+		return handler(w, r)
+	})
+}
+
+// trailingSlashVariant returns the path with its trailing slash added or
+// removed, reporting false for the root and catch-all paths
+func trailingSlashVariant(path string) (string, bool) {
+	switch {
+	case path == "/" || strings.HasSuffix(path, ":*}"):
+		return "", false
+	case strings.HasSuffix(path, "/"):
+		return path[:len(path)-1], true
+	default:
+		return path + "/", true
+	}
+}
+
+// wildMethods are the methods listed as allowed for routes registered with ANY
+var wildMethods = []string{
+	http.MethodGet,
+	http.MethodHead,
+	http.MethodPost,
+	http.MethodPut,
+	http.MethodPatch,
+	http.MethodDelete,
+	http.MethodConnect,
+	http.MethodTrace,
 }
 
 func (m *Mux) allowed(path, reqMethod string) (allow []string) {
 	allowed := make([]string, 0, 9)
+	found := false
+
+	add := func(methods ...string) {
+		if len(methods) == 1 && methods[0] == MethodWild {
+			methods = wildMethods
+		}
+
+		found = true
+		for _, method := range methods {
+			if !(m.AllowHeaderHideHEAD && method == http.MethodHead) && !slices.Contains(allowed, method) {
+				allowed = append(allowed, method)
+			}
+		}
+	}
 
 	if path == "*" || path == "/*" { // server-wide
 		// empty method is used for internal calls to refresh the cache
 		if reqMethod == "" {
-			for method := range m.registeredPaths {
+			for _, method := range m.methods {
 				if method == http.MethodOptions {
 					continue
 				}
 				// Add request method to list of allowed methods
-				allowed = append(allowed, method)
+				add(method)
 			}
 		} else {
 			return m.globalAllowed
 		}
 	} else { // specific path
-		for method := range m.registeredPaths {
+		for _, method := range m.methods {
 			// Skip the requested method - we already tried this one
 			if method == reqMethod || method == http.MethodOptions {
 				continue
 			}
 
-			handle, _ := m.trees[m.methodIndexOf(method)].Get(path, nil)
+			handle, _ := m.treeOf(method).Get(path, nil)
 			if handle != nil {
 				// Add request method to list of allowed methods
-				allowed = append(allowed, method)
+				add(method)
 			}
 		}
 	}
 
-	if len(allowed) > 0 {
+	if found {
 		// Add request method to list of allowed methods
 		allowed = append(allowed, http.MethodOptions)
 
-		// Sort allowed methods.
-		// sort.Strings(allowed) unfortunately causes unnecessary allocations
-		// due to allowed being moved to the heap and interface conversion
-		for i, l := 1, len(allowed); i < l; i++ {
-			for j := i; j > 0 && allowed[j] < allowed[j-1]; j-- {
-				allowed[j], allowed[j-1] = allowed[j-1], allowed[j]
+		if m.AllowHeaderOrder == AllowSorted {
+			// Sort allowed methods.
+			// sort.Strings(allowed) unfortunately causes unnecessary allocations
+			// due to allowed being moved to the heap and interface conversion
+			for i, l := 1, len(allowed); i < l; i++ {
+				for j := i; j > 0 && allowed[j] < allowed[j-1]; j-- {
+					allowed[j], allowed[j-1] = allowed[j-1], allowed[j]
+				}
 			}
 		}
 
@@ -447,70 +1620,151 @@ func (m *Mux) allowed(path, reqMethod string) (allow []string) {
 
 // getOptionalPaths returns all possible paths when the original path
 // has optional arguments
+//
+// An optional param, e.g. {name?} or {name?:regex}, must take up a whole
+// segment, which is dropped from the expanded path when omitted. Consecutive
+// optional segments are filled from left to right, so /a/{x?}/{y?}/b expands
+// into /a/b, /a/{x}/b and /a/{x}/{y}/b.
+//
+// Malformed optional params, e.g. {?}, {a?b?} or ones sharing a segment, are
+// kept as is, validatePath rejecting them at registration.
 func getOptionalPaths(path string) []string {
-	paths := make([]string, 0)
+	segments := splitSegments(path)
+
+	// runs hold the indices of consecutive optional segments
+	var runs [][]int
+	prev := -2
+	for i, seg := range segments {
+		stripped, ok := stripOptional(seg)
+		if !ok {
+			continue
+		}
 
-	start := 0
-walk:
-	for {
-		if start >= len(path) {
-			return paths
+		segments[i] = stripped
+		if prev == i-1 {
+			runs[len(runs)-1] = append(runs[len(runs)-1], i)
+		} else {
+			runs = append(runs, []int{i})
 		}
+		prev = i
+	}
+
+	if len(runs) == 0 {
+		return nil
+	}
 
-		c := path[start]
-		start++
+	// filled holds the number of included segments for every run
+	filled := make([]int, len(runs))
+	omitted := make([]bool, len(segments))
+	paths := make([]string, 0)
 
-		if c != '{' {
-			continue
+	for {
+		for i := range omitted {
+			omitted[i] = false
+		}
+		for r, run := range runs {
+			for _, i := range run[filled[r]:] {
+				omitted[i] = true
+			}
 		}
 
-		newPath := ""
-		hasRegex := false
-		questionMarkIndex := -1
+		var sb strings.Builder
+		for i, seg := range segments {
+			if !omitted[i] {
+				sb.WriteByte('/')
+				sb.WriteString(seg)
+			}
+		}
 
-		brackets := 0
+		p := sb.String()
+		if p == "" {
+			p = "/"
+		}
+		if !slices.Contains(paths, p) {
+			paths = append(paths, p)
+		}
 
-		for end, c := range []byte(path[start:]) {
-			switch c {
-			case '{':
-				brackets++
-			case '}':
-				if brackets > 0 {
-					brackets--
-					continue
-				} else if questionMarkIndex == -1 {
-					continue walk
-				}
+		// advance to the next combination
+		r := 0
+		for ; r < len(runs); r++ {
+			if filled[r] < len(runs[r]) {
+				filled[r]++
+				break
+			}
+			filled[r] = 0
+		}
+		if r == len(runs) {
+			return paths
+		}
+	}
+}
 
-				end++
-				newPath += path[questionMarkIndex+1 : start+end]
+// splitSegments splits path by the slashes that are not enclosed in braces,
+// omitting the leading slash
+func splitSegments(path string) []string {
+	if path == "" {
+		return nil
+	}
 
-				path = path[:questionMarkIndex] + path[questionMarkIndex+1:] // remove '?'
-				paths = append(paths, newPath)
-				start += end - 1
+	segments := make([]string, 0, strings.Count(path, "/"))
+
+	start, braces := 1, 0
+	for i := 1; i < len(path); i++ {
+		switch path[i] {
+		case '\\':
+			// escaped chars of a regex, e.g. \{, must not be counted
+			i++
+		case '{':
+			braces++
+		case '}':
+			if braces > 0 {
+				braces--
+			}
+		case '/':
+			if braces == 0 {
+				segments = append(segments, path[start:i])
+				start = i + 1
+			}
+		}
+	}
 
-				continue walk
-			case ':':
-				hasRegex = true
-			case '?':
-				if hasRegex {
-					continue
-				}
+	return append(segments, path[start:])
+}
 
-				questionMarkIndex = start + end
-				newPath += path[:questionMarkIndex]
+// stripOptional reports whether seg is a single optional param, returning it
+// without the question mark
+func stripOptional(seg string) (string, bool) {
+	if len(seg) < 3 || seg[0] != '{' || seg[len(seg)-1] != '}' {
+		return seg, false
+	}
 
-				if len(path[:start-2]) == 0 {
-					// include the root slash because the param is in the first segment
-					paths = append(paths, "/")
-				} else if !slices.Contains(paths, path[:start-2]) {
-					// include the path without the wildcard
-					// -2 due to remove the '/' and '{'
-					paths = append(paths, path[:start-2])
-				}
+	// the first brace must be closed by the last one
+	braces := 0
+	for i := 1; i < len(seg)-1; i++ {
+		switch seg[i] {
+		case '\\':
+			i++
+		case '{':
+			braces++
+		case '}':
+			if braces--; braces < 0 {
+				return seg, false
 			}
 		}
 	}
+
+	inner := seg[1 : len(seg)-1]
+	name, pattern, hasPattern := strings.Cut(inner, ":")
+	if len(name) < 2 || strings.IndexByte(name, '?') != len(name)-1 {
+		return seg, false
+	}
+
+	stripped := "{" + name[:len(name)-1]
+	if hasPattern {
+		stripped += ":" + pattern
+	}
+
+	return stripped + "}", true
 }
 
 func validatePath(path string) {
@@ -518,11 +1772,126 @@ func validatePath(path string) {
 	case len(path) == 0 || !strings.HasPrefix(path, "/"):
 		panic("path must begin with '/' in path '" + path + "'")
 	}
+
+	// an empty segment, e.g. in "/a//b", is rejected rather than matching
+	// either requests with the double slash or cleaned ones inconsistently
+	segments := splitSegments(path)
+	if slices.Contains(segments[:len(segments)-1], "") {
+		panic("path must not contain empty segments in path '" + path + "'")
+	}
+
+	for _, seg := range segments {
+		if _, ok := stripOptional(seg); !ok && hasOptionalMark(seg) {
+			panic("optional params must be named and take up a whole segment in path '" + path + "'")
+		}
+	}
+}
+
+// hasOptionalMark reports whether the name of any param within seg contains
+// a question mark, i.e. whether it's meant to be optional
+func hasOptionalMark(seg string) bool {
+	braces := 0
+	for i := 0; i < len(seg); i++ {
+		switch seg[i] {
+		case '\\':
+			i++
+		case '{':
+			if braces++; braces > 1 {
+				continue
+			}
+
+			end := i + 1 + strings.IndexAny(seg[i+1:], ":{}")
+			if end <= i {
+				end = len(seg)
+			}
+			if strings.IndexByte(seg[i+1:end], '?') > -1 {
+				return true
+			}
+		case '}':
+			if braces > 0 {
+				braces--
+			}
+		}
+	}
+
+	return false
+}
+
+// isToken reports whether s is a valid token as defined by RFC 9110,
+// which all method names must be
+func isToken(s string) bool {
+	for _, c := range []byte(s) {
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		case strings.IndexByte("!#$%&'*+-.^_`|~", c) > -1:
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+// cleanPath cleans the path like path.Clean, keeping the trailing slash
+func cleanPath(p string) string {
+	cleaned := pathpkg.Clean(p)
+	if cleaned != "/" && strings.HasSuffix(p, "/") {
+		cleaned += "/"
+	}
+	return cleaned
+}
+
+// validEscapes checks that every '%' in s begins an escape of two hex digits
+func validEscapes(s string) bool {
+	for i := strings.IndexByte(s, '%'); i > -1; i = strings.IndexByte(s, '%') {
+		if len(s) < i+3 || !isHex(s[i+1]) || !isHex(s[i+2]) {
+			return false
+		}
+		s = s[i+3:]
+	}
+
+	return true
+}
+
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
 }
 
 // MethodWild wild HTTP method
 const MethodWild = "*"
 
+// treeOf returns the tree of the method, nil if nothing was registered for it
+func (m *Mux) treeOf(method string) *radix.Tree {
+	switch method {
+	case http.MethodGet:
+		return m.trees[0]
+	case http.MethodHead:
+		return m.trees[1]
+	case http.MethodPost:
+		return m.trees[2]
+	case http.MethodPut:
+		return m.trees[3]
+	case http.MethodPatch:
+		return m.trees[4]
+	case http.MethodDelete:
+		return m.trees[5]
+	case http.MethodConnect:
+		return m.trees[6]
+	case http.MethodOptions:
+		return m.trees[7]
+	case http.MethodTrace:
+		return m.trees[8]
+	case MethodWild:
+		return m.wildTree
+	}
+
+	if i, ok := m.customMethodsIndex[method]; ok {
+		return m.trees[i]
+	}
+
+	return nil
+}
+
 func (m *Mux) methodIndexOf(method string) int {
 	switch method {
 	case http.MethodGet:
@@ -1,18 +1,25 @@
 package httx
 
 import (
+	"bufio"
 	"bytes"
 	"embed"
+	"errors"
 	"fmt"
 	"io"
+	"maps"
 	"math/rand"
 	"net"
 	"net/http"
 	"net/http/httptest"
 	"reflect"
+	"regexp"
 	"runtime"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"testing/fstest"
 	"time"
 
 	"slices"
@@ -293,6 +300,39 @@ func TestRouterInvalidInput(t *testing.T) {
 	if recv == nil {
 		t.Fatal("registering nil handler did not panic")
 	}
+
+	recv = catchPanic(func() {
+		router.GET("/a//b", handle)
+	})
+	if recv == nil {
+		t.Fatal("registering path with an empty segment did not panic")
+	}
+
+	for _, path := range []string{"/{?}", "/{?:\\d+}", "/{a?b?}", "/{a??}", "/x{a?}", "/{a?}{b}"} {
+		if recv := catchPanic(func() { router.GET(path, handle) }); recv == nil {
+			t.Errorf("registering malformed optional param in path %q did not panic", path)
+		}
+	}
+}
+
+func TestRouterEmptySegments(t *testing.T) {
+	router := NewMux()
+
+	handle := func(http.ResponseWriter, *http.Request) error { return nil }
+	router.GET("/a/b", handle)
+	router.GET("/a/b/", handle)
+	router.GET(`/url/{target:https?://.+}`, handle)
+
+	for _, path := range []string{"/a//b", "//a/b", "/a/b//"} {
+		if rec := router.TestRequest(http.MethodGet, path, nil); rec.Code != http.StatusNotFound {
+			t.Errorf("GET %s: got %d, want %d", path, rec.Code, http.StatusNotFound)
+		}
+	}
+
+	// double slashes within params are no empty segments
+	if rec := router.TestRequest(http.MethodGet, "/url/https://example.com", nil); rec.Code != http.StatusOK {
+		t.Errorf("GET /url/https://example.com: got %d, want %d", rec.Code, http.StatusOK)
+	}
 }
 
 func TestRouterRegexUserValues(t *testing.T) {
@@ -568,6 +608,30 @@ func TestRouterNotAllowed(t *testing.T) {
 	}
 }
 
+func TestRouterNotAllowedUnknownMethod(t *testing.T) {
+	handlerFunc := func(http.ResponseWriter, *http.Request) error { return nil }
+
+	router := NewMux()
+	router.GET("/coffee", handlerFunc)
+
+	checkHandling := func(method, path, expectedAllowed string, expectedStatusCode int) {
+		rec := router.TestRequest(method, path, nil)
+		if rec.Code != expectedStatusCode {
+			t.Errorf("%s %s: got %d, want %d", method, path, rec.Code, expectedStatusCode)
+		} else if allow := strings.Join(rec.Header().Values("Allow"), ", "); allow != expectedAllowed {
+			t.Errorf("%s %s: Allow == %q, want %q", method, path, allow, expectedAllowed)
+		}
+	}
+
+	checkHandling("BREW", "/coffee", "GET, OPTIONS", http.StatusMethodNotAllowed)
+	checkHandling("BREW", "/tea", "", http.StatusNotFound)
+
+	// a tree for another custom method doesn't change anything
+	router.Handle("PURGE", "/cache", handlerFunc)
+	checkHandling("BREW", "/coffee", "GET, OPTIONS", http.StatusMethodNotAllowed)
+	checkHandling("PURGE", "/coffee", "GET, OPTIONS", http.StatusMethodNotAllowed)
+}
+
 func testRouterNotFoundByMethod(t *testing.T, method string) {
 	handlerFunc := func(http.ResponseWriter, *http.Request) error { return nil }
 
@@ -1056,6 +1120,39 @@ func TestRouterList(t *testing.T) {
 
 }
 
+func TestRouterMergeMountPrefixSlash(t *testing.T) {
+	sub := NewMux()
+	sub.GET("/x", func(w http.ResponseWriter, r *http.Request) error {
+		_, err := w.Write([]byte(r.URL.Path))
+		return err
+	})
+
+	r := NewMux()
+	if recv := catchPanic(func() { r.Merge("/api/", sub) }); recv != nil {
+		t.Fatalf("Merge(\"/api/\") panicked: %v", recv)
+	}
+	if recv := catchPanic(func() { r.Mount("/mnt/", sub) }); recv != nil {
+		t.Fatalf("Mount(\"/mnt/\") panicked: %v", recv)
+	}
+
+	tests := []struct {
+		path string
+		code int
+		body string
+	}{
+		{"/api/x", http.StatusOK, "/api/x"},
+		{"/api//x", http.StatusNotFound, ""},
+		{"/mnt/x", http.StatusOK, "/x"},
+	}
+
+	for _, test := range tests {
+		rec := r.TestRequest(http.MethodGet, test.path, nil)
+		if rec.Code != test.code || rec.Body.String() != test.body {
+			t.Errorf("GET %s: got %d %q, want %d %q", test.path, rec.Code, rec.Body.String(), test.code, test.body)
+		}
+	}
+}
+
 func TestRouterSamePrefixParamRoute(t *testing.T) {
 	var id1, id2, id3, pageSize, page, iid string
 	var routed1, routed2, routed3 bool
@@ -1132,7 +1229,7 @@ func TestRouterSamePrefixParamRoute(t *testing.T) {
 
 func TestGetOptionalPath(t *testing.T) {
 	handler := func(w http.ResponseWriter, r *http.Request) error {
-		w.WriteHeader(http.StatusOK)
+		w.WriteHeader(http.StatusAccepted)
 		return nil
 	}
 
@@ -1141,16 +1238,20 @@ func TestGetOptionalPath(t *testing.T) {
 		tsr     bool
 		handler HandlerFunc
 	}{
-		{"/show/{name}", false, handler},
-		{"/show/{name}/", true, nil},
-		{"/show/{name}/{surname}", false, handler},
-		{"/show/{name}/{surname}/", true, nil},
-		{"/show/{name}/{surname}/at", false, handler},
-		{"/show/{name}/{surname}/at/", true, nil},
-		{"/show/{name}/{surname}/at/{address}", false, handler},
-		{"/show/{name}/{surname}/at/{address}/", true, nil},
+		{"/show/{name}/at/{id}", false, handler},
+		{"/show/{name}/at/{id}/", true, nil},
+		{"/show/{name}/{surname}/at/{id}", false, handler},
+		{"/show/{name}/{surname}/at/{id}/", true, nil},
+		{"/show/{name}/at/{address}/{id}", false, handler},
+		{"/show/{name}/at/{address}/{id}/", true, nil},
 		{"/show/{name}/{surname}/at/{address}/{id}", false, handler},
 		{"/show/{name}/{surname}/at/{address}/{id}/", true, nil},
+		{"/show/{name}/at/{id}/{phone:.*}", false, handler},
+		{"/show/{name}/at/{id}/{phone:.*}/", true, nil},
+		{"/show/{name}/{surname}/at/{id}/{phone:.*}", false, handler},
+		{"/show/{name}/{surname}/at/{id}/{phone:.*}/", true, nil},
+		{"/show/{name}/at/{address}/{id}/{phone:.*}", false, handler},
+		{"/show/{name}/at/{address}/{id}/{phone:.*}/", true, nil},
 		{"/show/{name}/{surname}/at/{address}/{id}/{phone:.*}", false, handler},
 		{"/show/{name}/{surname}/at/{address}/{id}/{phone:.*}/", true, nil},
 	}
@@ -1167,8 +1268,14 @@ func TestGetOptionalPath(t *testing.T) {
 			t.Errorf("TSR (path: %s) == %v, want %v", e.path, tsr, e.tsr)
 		}
 
-		if h != nil && e.handler != nil && reflect.ValueOf(h) != reflect.ValueOf(e.handler) {
-			t.Errorf("Handler (path: %s) == %p, want %p", e.path, h, e.handler)
+		// handlers are wrapped on registration, so they're told apart by
+		// their response
+		if h != nil && e.handler != nil {
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			if rec.Code != http.StatusAccepted {
+				t.Errorf("Handler (path: %s) responded with %d, want %d", e.path, rec.Code, http.StatusAccepted)
+			}
 		}
 	}
 
@@ -1182,6 +1289,16 @@ func TestGetOptionalPath(t *testing.T) {
 		{"/{filepath:^(?!api).*}", nil},
 		{"/static/{filepath?:^(?!api).*}", []string{"/static", "/static/{filepath:^(?!api).*}"}},
 		{"/show/{name?}", []string{"/show", "/show/{name}"}},
+		{"/a/{x?}/b", []string{"/a/b", "/a/{x}/b"}},
+		{"/a/{x?}/{y?}/b", []string{"/a/b", "/a/{x}/b", "/a/{x}/{y}/b"}},
+		{"/a/{x?:\\d+}/b", []string{"/a/b", "/a/{x:\\d+}/b"}},
+		{"/files/{category?}/list/", []string{"/files/list/", "/files/{category}/list/"}},
+		{"/a/{x?}/b/{y?}", []string{"/a/b", "/a/{x}/b", "/a/b/{y}", "/a/{x}/b/{y}"}},
+		{`/{id?:\d{3,6}}`, []string{"/", `/{id:\d{3,6}}`}},
+		{`/{code?:[A-Z]{2}\d{4}}`, []string{"/", `/{code:[A-Z]{2}\d{4}}`}},
+		{`/a/{code?:[A-Z]{2}\d{4}}/{id?:\d{3,6}}`, []string{"/a", `/a/{code:[A-Z]{2}\d{4}}`, `/a/{code:[A-Z]{2}\d{4}}/{id:\d{3,6}}`}},
+		{`/a/{x?:\{+}/b`, []string{"/a/b", `/a/{x:\{+}/b`}},
+		{`/a/{x?:[a-z]{2}/\d}/b`, []string{"/a/b", `/a/{x:[a-z]{2}/\d}/b`}},
 	}
 
 	for _, test := range tests {
@@ -1198,3 +1315,2061 @@ func TestGetOptionalPath(t *testing.T) {
 		}
 	}
 }
+
+func FuzzGetOptionalPaths(f *testing.F) {
+	f.Add("/a/{x?}/{y?}/b")
+	f.Add(`/a/{code?:[A-Z]{2}\d{4}}/{id?:\d{3,6}}`)
+	f.Add("/{?}")
+	f.Add("/{:}")
+	f.Add("/{?:}")
+	f.Add("/{a?b?}")
+	f.Add("/{a??}")
+	f.Add("/{a?")
+	f.Add("/a?}/{b")
+	f.Add("/{{a?}}")
+	f.Add("/x{a?}")
+	f.Add("")
+	f.Add("{a?}")
+
+	f.Fuzz(func(t *testing.T, path string) {
+		paths := getOptionalPaths(path)
+
+		if catchPanic(func() { validatePath(path) }) != nil {
+			return
+		}
+
+		for _, p := range paths {
+			if !strings.HasPrefix(p, "/") {
+				t.Fatalf("getOptionalPaths(%q) returned %q not beginning with '/'", path, p)
+			}
+			if recv := catchPanic(func() { validatePath(p) }); recv != nil {
+				t.Fatalf("getOptionalPaths(%q) returned invalid %q: %v", path, p, recv)
+			}
+			for _, seg := range splitSegments(p) {
+				if _, ok := stripOptional(seg); ok {
+					t.Fatalf("getOptionalPaths(%q) returned %q with optional segment %q", path, p, seg)
+				}
+			}
+		}
+	})
+}
+
+func TestRouterDuplicateRoute(t *testing.T) {
+	handler := func(http.ResponseWriter, *http.Request) error { return nil }
+
+	r := NewMux()
+	r.GET("/users/{id}", handler)
+
+	recv := catchPanic(func() {
+		r.GET("/users/{id}", handler)
+	})
+	if recv != "httx: duplicate route GET /users/{id}" {
+		t.Errorf("duplicate registration panic == %v, want %q", recv, "httx: duplicate route GET /users/{id}")
+	}
+
+	if recv := catchPanic(func() { r.POST("/users/{id}", handler) }); recv != nil {
+		t.Errorf("registering the same path with another method panicked: %v", recv)
+	}
+
+	var updated bool
+	r.Mutable(true)
+
+	recv = catchPanic(func() {
+		r.GET("/users/{id}", func(http.ResponseWriter, *http.Request) error {
+			updated = true
+			return nil
+		})
+	})
+	if recv != nil {
+		t.Fatalf("duplicate registration in mutable mode panicked: %v", recv)
+	}
+
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/users/1", nil))
+	if !updated {
+		t.Error("handler was not updated in mutable mode")
+	}
+
+	if paths := r.List()[http.MethodGet]; len(paths) != 1 {
+		t.Errorf("Router.List()[GET] == %v, want a single path", paths)
+	}
+}
+
+func TestRouterInvalidRegex(t *testing.T) {
+	r := NewMux()
+
+	recv := catchPanic(func() {
+		r.GET("/users/{id:[}", func(http.ResponseWriter, *http.Request) error { return nil })
+	})
+	if recv == nil {
+		t.Fatal("registering an invalid regex did not panic")
+	}
+
+	if msg := fmt.Sprint(recv); !strings.Contains(msg, "/users/{id:[}") {
+		t.Errorf("panic message does not mention the route: %s", msg)
+	}
+}
+
+func TestRouterMiddlewareOnNotFound(t *testing.T) {
+	var count int
+
+	r := NewMux()
+	r.Pre(func(next HandlerFunc) HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) error {
+			count++
+			return next(w, r)
+		}
+	})
+	r.POST("/path", func(http.ResponseWriter, *http.Request) error { return nil })
+
+	request := func(method, path string, wantCode int) {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(method, path, nil))
+		if rec.Code != wantCode {
+			t.Errorf("%s %s status == %d, want %d", method, path, rec.Code, wantCode)
+		}
+	}
+
+	request(http.MethodGet, "/nope", http.StatusNotFound)
+	if count != 0 {
+		t.Errorf("middleware ran %d times with MiddlewareOnNotFound disabled", count)
+	}
+
+	r.MiddlewareOnNotFound = true
+
+	request(http.MethodGet, "/nope", http.StatusNotFound)
+	if count != 1 {
+		t.Errorf("middleware ran %d times on 404, want 1", count)
+	}
+
+	request(http.MethodGet, "/path", http.StatusMethodNotAllowed)
+	if count != 2 {
+		t.Errorf("middleware ran %d times on 405, want 2", count)
+	}
+
+	request(http.MethodOptions, "/path", http.StatusOK)
+	if count != 3 {
+		t.Errorf("middleware ran %d times on automatic OPTIONS, want 3", count)
+	}
+}
+
+func TestRouterMidPathOptional(t *testing.T) {
+	var category, x, y string
+
+	r := NewMux()
+	r.GET("/files/{category?}/list", func(w http.ResponseWriter, r *http.Request) error {
+		category = r.PathValue("category")
+		return nil
+	})
+	r.GET(`/a/{x?:\d+}/{y?}/b`, func(w http.ResponseWriter, r *http.Request) error {
+		x, y = r.PathValue("x"), r.PathValue("y")
+		return nil
+	})
+
+	tests := []struct {
+		path           string
+		code           int
+		category, x, y string
+	}{
+		{"/files/list", http.StatusOK, "", "", ""},
+		{"/files/docs/list", http.StatusOK, "docs", "", ""},
+		{"/a/b", http.StatusOK, "", "", ""},
+		{"/a/1/b", http.StatusOK, "", "1", ""},
+		{"/a/1/2/b", http.StatusOK, "", "1", "2"},
+		{"/a/x/b", http.StatusNotFound, "", "", ""},
+	}
+
+	for _, test := range tests {
+		category, x, y = "", "", ""
+
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, test.path, nil))
+
+		if rec.Code != test.code {
+			t.Errorf("%s status == %d, want %d", test.path, rec.Code, test.code)
+		}
+		if category != test.category || x != test.x || y != test.y {
+			t.Errorf("%s params == (%q, %q, %q), want (%q, %q, %q)", test.path, category, x, y, test.category, test.x, test.y)
+		}
+	}
+}
+
+func TestRouterOptionalRegexQuantifiers(t *testing.T) {
+	var id, code string
+
+	r := NewMux()
+	r.GET(`/id/{id?:\d{3,6}}`, func(w http.ResponseWriter, r *http.Request) error {
+		id = r.PathValue("id")
+		return nil
+	})
+	r.GET(`/code/{code?:[A-Z]{2}\d{4}}`, func(w http.ResponseWriter, r *http.Request) error {
+		code = r.PathValue("code")
+		return nil
+	})
+
+	tests := []struct {
+		path   string
+		code   int
+		id, cd string
+	}{
+		{"/id", http.StatusOK, "", ""},
+		{"/id/1234", http.StatusOK, "1234", ""},
+		{"/id/12", http.StatusNotFound, "", ""},
+		{"/code", http.StatusOK, "", ""},
+		{"/code/UA1234", http.StatusOK, "", "UA1234"},
+		{"/code/U1234", http.StatusNotFound, "", ""},
+	}
+
+	for _, test := range tests {
+		id, code = "", ""
+
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, test.path, nil))
+
+		if rec.Code != test.code {
+			t.Errorf("%s status == %d, want %d", test.path, rec.Code, test.code)
+		}
+		if id != test.id || code != test.cd {
+			t.Errorf("%s params == (%q, %q), want (%q, %q)", test.path, id, code, test.id, test.cd)
+		}
+	}
+}
+
+func TestRouterValidate(t *testing.T) {
+	handler := func(http.ResponseWriter, *http.Request) error { return nil }
+
+	r := NewMux()
+	r.GET(`/users/{id:\d+}/info`, handler)
+	r.GET(`/users/{name}/details`, handler)
+	r.GET(`/users/{name}/{tab}`, handler)
+	r.POST(`/users/{id}`, handler)
+
+	if errs := r.Validate(); len(errs) != 0 {
+		t.Errorf("Router.Validate() == %v, want no errors", errs)
+	}
+
+	r.GET(`/users/{num:\d+}/info`, handler)
+
+	errs := r.Validate()
+	if len(errs) != 1 {
+		t.Fatalf("Router.Validate() == %v, want a single error", errs)
+	}
+
+	want := `GET route '/users/{num:\d+}/info' is unreachable, shadowed by '/users/{id:\d+}/info'`
+	if errs[0].Error() != want {
+		t.Errorf("Router.Validate() == %q, want %q", errs[0], want)
+	}
+}
+
+func TestRouterCustomMethods(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) error {
+		w.WriteHeader(http.StatusMultiStatus)
+		return nil
+	}
+
+	r := NewMux()
+	for _, method := range []string{"PROPFIND", "MKCOL", "COPY", "MOVE", "LOCK"} {
+		r.Handle(method, "/dav", handler)
+	}
+	r.GET("/dav", handler)
+
+	rec := r.TestRequest("PROPFIND", "/dav", nil)
+	if rec.Code != http.StatusMultiStatus {
+		t.Errorf("PROPFIND status == %d, want %d", rec.Code, http.StatusMultiStatus)
+	}
+
+	rec = r.TestRequest(http.MethodOptions, "/dav", nil)
+	if allow := strings.Join(rec.Header().Values("Allow"), ", "); allow != "COPY, GET, LOCK, MKCOL, MOVE, OPTIONS, PROPFIND" {
+		t.Errorf("unexpected Allow header value: %s", allow)
+	}
+
+	rec = r.TestRequest(http.MethodDelete, "/dav", nil)
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("DELETE status == %d, want %d", rec.Code, http.StatusMethodNotAllowed)
+	}
+
+	for _, method := range []string{"PROP FIND", "GET\n", "M(KCOL)", "LOCK/"} {
+		if recv := catchPanic(func() { r.Handle(method, "/dav", handler) }); recv == nil {
+			t.Errorf("registering invalid method %q did not panic", method)
+		}
+	}
+}
+
+func TestRouterNormalizeMethod(t *testing.T) {
+	r := NewMux()
+	r.GET("/path", func(w http.ResponseWriter, r *http.Request) error {
+		if r.Method != http.MethodGet {
+			t.Errorf("r.Method == %q, want %q", r.Method, http.MethodGet)
+		}
+		return nil
+	})
+
+	if rec := r.TestRequest("get", "/path", nil); rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("status == %d, want %d", rec.Code, http.StatusMethodNotAllowed)
+	}
+
+	r.NormalizeMethod = true
+
+	for _, method := range []string{"get", "Get", "GET"} {
+		if rec := r.TestRequest(method, "/path", nil); rec.Code != http.StatusOK {
+			t.Errorf("%s status == %d, want %d", method, rec.Code, http.StatusOK)
+		}
+	}
+
+	req := httptest.NewRequest("get", "/path", nil)
+	r.ServeHTTP(httptest.NewRecorder(), req)
+	if req.Method != "get" {
+		t.Errorf("caller's r.Method == %q after ServeHTTP, want %q", req.Method, "get")
+	}
+}
+
+func BenchmarkRouterServeHTTP(b *testing.B) {
+	handler := func(http.ResponseWriter, *http.Request) error { return nil }
+
+	r := NewMux()
+	r.GET("/plaintext", handler)
+	r.Handle("CUSTOM", "/custom", handler)
+	r.ANY("/any", handler)
+
+	for _, bench := range []struct{ method, path string }{
+		{http.MethodGet, "/plaintext"},
+		{"CUSTOM", "/custom"},
+		{http.MethodGet, "/any"},
+		{"CUSTOM", "/any"},
+	} {
+		b.Run(bench.method+bench.path, func(b *testing.B) {
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(bench.method, bench.path, nil)
+
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				r.ServeHTTP(rec, req)
+			}
+		})
+	}
+}
+
+func TestRouterClone(t *testing.T) {
+	var calls int
+
+	r := NewMux()
+	r.Pre(func(next HandlerFunc) HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) error {
+			calls++
+			return next(w, r)
+		}
+	})
+	r.GET("/base/{id}", func(http.ResponseWriter, *http.Request) error { return nil })
+
+	c := r.Clone()
+	c.GET("/base/{id}/extra", func(http.ResponseWriter, *http.Request) error { return nil })
+	c.Handle("CUSTOM", "/custom", func(http.ResponseWriter, *http.Request) error { return nil })
+	c.ANY("/any", func(http.ResponseWriter, *http.Request) error { return nil })
+	c.Pre(func(next HandlerFunc) HandlerFunc { return next })
+
+	for _, path := range []string{"/base/1", "/base/1/extra", "/any"} {
+		if rec := c.TestRequest(http.MethodGet, path, nil); rec.Code != http.StatusOK {
+			t.Errorf("clone GET %s status == %d, want %d", path, rec.Code, http.StatusOK)
+		}
+	}
+	if calls != 3 {
+		t.Errorf("middleware ran %d times on the clone, want 3", calls)
+	}
+
+	if rec := r.TestRequest(http.MethodGet, "/base/1", nil); rec.Code != http.StatusOK {
+		t.Errorf("original GET /base/1 status == %d, want %d", rec.Code, http.StatusOK)
+	}
+	for _, path := range []string{"/base/1/extra", "/any"} {
+		if rec := r.TestRequest(http.MethodGet, path, nil); rec.Code != http.StatusNotFound {
+			t.Errorf("original GET %s status == %d, want %d", path, rec.Code, http.StatusNotFound)
+		}
+	}
+	if rec := r.TestRequest("CUSTOM", "/custom", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("original CUSTOM /custom status == %d, want %d", rec.Code, http.StatusNotFound)
+	}
+
+	if paths := r.List()[http.MethodGet]; len(paths) != 1 {
+		t.Errorf("original Router.List()[GET] == %v, want a single path", paths)
+	}
+	if len(r.mw) != 1 {
+		t.Errorf("original middleware count == %d, want 1", len(r.mw))
+	}
+}
+
+func TestRouterCloneHandleHost(t *testing.T) {
+	var calls int
+
+	r := NewMux()
+	r.Pre(func(next HandlerFunc) HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) error {
+			calls++
+			return next(w, r)
+		}
+	})
+	r.HandleHost("acme.example.com", http.MethodGet, "/", func(w http.ResponseWriter, r *http.Request) error {
+		_, err := w.Write([]byte("acme"))
+		return err
+	})
+
+	c := r.Clone()
+	c.HandleHost("globex.example.com", http.MethodGet, "/", func(w http.ResponseWriter, r *http.Request) error {
+		_, err := w.Write([]byte("globex"))
+		return err
+	})
+	r.HandleHost("initech.example.com", http.MethodGet, "/", func(w http.ResponseWriter, r *http.Request) error {
+		_, err := w.Write([]byte("initech"))
+		return err
+	})
+
+	tests := []struct {
+		mux  *Mux
+		name string
+		host string
+		code int
+		body string
+	}{
+		{r, "original", "acme.example.com", http.StatusOK, "acme"},
+		{r, "original", "globex.example.com", http.StatusNotFound, ""},
+		{r, "original", "initech.example.com", http.StatusOK, "initech"},
+		{c, "clone", "acme.example.com", http.StatusOK, "acme"},
+		{c, "clone", "globex.example.com", http.StatusOK, "globex"},
+		{c, "clone", "initech.example.com", http.StatusNotFound, ""},
+	}
+
+	for _, test := range tests {
+		calls = 0
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Host = test.host
+
+		rec := httptest.NewRecorder()
+		test.mux.ServeHTTP(rec, req)
+		if rec.Code != test.code || rec.Body.String() != test.body {
+			t.Errorf("%s GET / on %s: got %d %q, want %d %q", test.name, test.host, rec.Code, rec.Body.String(), test.code, test.body)
+		}
+		if calls != 1 {
+			t.Errorf("%s GET / on %s: middleware ran %d times, want 1", test.name, test.host, calls)
+		}
+	}
+}
+
+func TestRouterCloneServingMux(t *testing.T) {
+	r := NewMux()
+	r.GET("/routes", r.RoutesHandler())
+	r.Merge("/static/*", http.NotFoundHandler())
+
+	c := r.Clone()
+	c.OnNotFound = func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}
+	c.DisableRoutesHandler = true
+
+	tests := []struct {
+		mux  *Mux
+		name string
+		path string
+		code int
+	}{
+		{r, "original", "/routes", http.StatusOK},
+		{c, "clone", "/routes", http.StatusTeapot},
+		{c, "clone", "/nope", http.StatusTeapot},
+	}
+
+	for _, test := range tests {
+		if rec := test.mux.TestRequest(http.MethodGet, test.path, nil); rec.Code != test.code {
+			t.Errorf("%s GET %s: got %d, want %d", test.name, test.path, rec.Code, test.code)
+		}
+	}
+
+	// the merged handler falls back to the not found handler of the clone
+	req := httptest.NewRequest(http.MethodGet, "/static/x", nil)
+	req.URL.RawPath = "/other/x"
+	rec := httptest.NewRecorder()
+	c.ServeHTTP(rec, req)
+	if rec.Code != http.StatusTeapot {
+		t.Errorf("clone GET /static/x with a mismatching raw path: got %d, want %d", rec.Code, http.StatusTeapot)
+	}
+}
+
+func TestRouterBothTrailingSlashVariants(t *testing.T) {
+	r := NewMux()
+	r.RedirectTrailingSlash = true
+
+	for _, path := range []string{"/foo", "/foo/", "/users/{id}", "/users/{id}/", "/files", "/files/{filepath:*}"} {
+		r.GET(path, func(w http.ResponseWriter, r *http.Request) error {
+			_, err := w.Write([]byte(path))
+			return err
+		})
+	}
+
+	tests := []struct {
+		path, want string
+	}{
+		{"/foo", "/foo"},
+		{"/foo/", "/foo/"},
+		{"/users/1", "/users/{id}"},
+		{"/users/1/", "/users/{id}/"},
+		{"/files", "/files"},
+		{"/files/", "/files/{filepath:*}"},
+	}
+
+	for _, test := range tests {
+		rec := r.TestRequest(http.MethodGet, test.path, nil)
+		if rec.Code != http.StatusOK || rec.Body.String() != test.want {
+			t.Errorf("GET %s: got %d %q, want 200 %q", test.path, rec.Code, rec.Body.String(), test.want)
+		}
+	}
+}
+
+func TestRouterMergeTrailingSlash(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) error {
+		_, err := w.Write([]byte(r.PathValue("id") + r.PathValue("filepath")))
+		return err
+	}
+
+	r := NewMux()
+	r.MergeTrailingSlash = true
+	r.GET("/foo", handler)
+	r.GET("/bar/", handler)
+	r.GET("/users/{id}", handler)
+	r.GET(`/posts/{id:\d+}/`, handler)
+	r.GET("/static/{filepath:*}", handler)
+	r.GET("/", handler)
+
+	r.GET("/explicit/", func(w http.ResponseWriter, r *http.Request) error {
+		_, err := w.Write([]byte("slash"))
+		return err
+	})
+	r.GET("/explicit", handler)
+	r.GET("/other", handler)
+	r.GET("/other/", func(w http.ResponseWriter, r *http.Request) error {
+		_, err := w.Write([]byte("slash"))
+		return err
+	})
+
+	tests := []struct {
+		path string
+		code int
+		body string
+	}{
+		{"/foo", http.StatusOK, ""},
+		{"/foo/", http.StatusOK, ""},
+		{"/bar", http.StatusOK, ""},
+		{"/bar/", http.StatusOK, ""},
+		{"/users/1", http.StatusOK, "1"},
+		{"/users/1/", http.StatusOK, "1"},
+		{"/posts/2", http.StatusOK, "2"},
+		{"/posts/2/", http.StatusOK, "2"},
+		{"/static/a/b/", http.StatusOK, "a/b/"},
+		{"/static/", http.StatusOK, ""},
+		{"/", http.StatusOK, ""},
+		{"/explicit", http.StatusOK, ""},
+		{"/explicit/", http.StatusOK, "slash"},
+		{"/other", http.StatusOK, ""},
+		{"/other/", http.StatusOK, "slash"},
+	}
+
+	for _, test := range tests {
+		rec := r.TestRequest(http.MethodGet, test.path, nil)
+		if rec.Code != test.code || rec.Body.String() != test.body {
+			t.Errorf("GET %s == %d %q, want %d %q", test.path, rec.Code, rec.Body.String(), test.code, test.body)
+		}
+	}
+}
+
+func TestGroupHandlers(t *testing.T) {
+	r := NewMux()
+	r.GET("/public", func(http.ResponseWriter, *http.Request) error { return errors.New("public") })
+
+	admin := r.Group("/admin")
+	admin.OnNotFound(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+	admin.OnMethodNotAllowed(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusConflict)
+	})
+	admin.OnError(func(w http.ResponseWriter, r *http.Request, err error) {
+		w.WriteHeader(http.StatusBadGateway)
+	})
+	admin.GET("/users", func(http.ResponseWriter, *http.Request) error { return errors.New("admin") })
+
+	reports := admin.Group("/reports")
+	reports.OnNotFound(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusGone)
+	})
+	reports.GET("/{id}", func(http.ResponseWriter, *http.Request) error { return errors.New("reports") })
+
+	tests := []struct {
+		method, path string
+		code         int
+	}{
+		{http.MethodGet, "/nope", http.StatusNotFound},
+		{http.MethodGet, "/public", http.StatusInternalServerError},
+		{http.MethodPost, "/public", http.StatusMethodNotAllowed},
+		{http.MethodGet, "/admin", http.StatusTeapot},
+		{http.MethodGet, "/admin/", http.StatusTeapot},
+		{http.MethodGet, "/admin/nope", http.StatusTeapot},
+		{http.MethodGet, "/administrators", http.StatusNotFound},
+		{http.MethodGet, "/admin/users", http.StatusBadGateway},
+		{http.MethodPost, "/admin/users", http.StatusConflict},
+		{http.MethodGet, "/admin/reports/1/nope", http.StatusGone},
+		{http.MethodPost, "/admin/reports/1", http.StatusConflict},
+		{http.MethodGet, "/admin/reports/1", http.StatusBadGateway},
+	}
+
+	for _, test := range tests {
+		if rec := r.TestRequest(test.method, test.path, nil); rec.Code != test.code {
+			t.Errorf("%s %s status == %d, want %d", test.method, test.path, rec.Code, test.code)
+		}
+	}
+}
+
+func TestRouterMethods(t *testing.T) {
+	handler := func(http.ResponseWriter, *http.Request) error { return nil }
+
+	r := NewMux()
+	r.POST("/users/{id}", handler)
+	r.GET("/users/{id}", handler)
+	r.GET("/users", handler)
+	r.ANY("/any", handler)
+	r.Handle("PROPFIND", "/any", handler)
+
+	tests := []struct {
+		path string
+		want []string
+	}{
+		{"/users/{id}", []string{"GET", "POST"}},
+		{"/users", []string{"GET"}},
+		{"/any", []string{"*", "PROPFIND"}},
+		{"/users/1", nil},
+	}
+
+	for _, test := range tests {
+		if got := r.Methods(test.path); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("Router.Methods(%q) == %v, want %v", test.path, got, test.want)
+		}
+	}
+}
+
+func TestRouterMergeHandler(t *testing.T) {
+	r := NewMux()
+	r.Merge("/static/*", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.URL.Path))
+	}))
+	// set after merging, must still be respected
+	r.OnNotFound = func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/static/*", nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != "*" {
+		t.Errorf("matching prefix: got %d %q, want 200 \"*\"", rec.Code, rec.Body.String())
+	}
+
+	// same path, but the escaped form does not carry the prefix
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/st%61tic/*", nil))
+	if rec.Code != http.StatusTeapot {
+		t.Errorf("non-matching prefix: got %d, want %d", rec.Code, http.StatusTeapot)
+	}
+}
+
+func TestRouterMergeFileServer(t *testing.T) {
+	fsys := fstest.MapFS{
+		"hello.txt":   {Data: []byte("hello")},
+		"css/app.css": {Data: []byte("body{}")},
+	}
+
+	r := NewMux()
+	r.Merge("/fs/*", http.FileServer(http.FS(fsys)))
+	r.Merge("/files/{path:*}", http.FileServer(http.FS(fsys)))
+
+	for _, prefix := range []string{"/fs", "/files"} {
+		for path, body := range map[string]string{"/hello.txt": "hello", "/css/app.css": "body{}"} {
+			rec := r.TestRequest(http.MethodGet, prefix+path, nil)
+			if rec.Code != http.StatusOK || rec.Body.String() != body {
+				t.Errorf("GET %s%s: got %d %q, want 200 %q", prefix, path, rec.Code, rec.Body.String(), body)
+			}
+		}
+
+		if rec := r.TestRequest(http.MethodGet, prefix+"/missing.txt", nil); rec.Code != http.StatusNotFound {
+			t.Errorf("GET %s/missing.txt: got %d, want %d", prefix, rec.Code, http.StatusNotFound)
+		}
+	}
+
+	for _, prefix := range []string{"/fs", "/fs/", "/fs/{path}", "/fs*"} {
+		if recv := catchPanic(func() { r.Merge(prefix, http.NotFoundHandler()) }); recv == nil {
+			t.Errorf("merging a handler at %q did not panic", prefix)
+		}
+	}
+}
+
+func TestRouterHandleRegex(t *testing.T) {
+	var pre int
+	r := NewMux()
+	r.Pre(func(next HandlerFunc) HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) error {
+			pre++
+			return next(w, r)
+		}
+	})
+	r.HandleRegex(http.MethodGet, "/orders/{id}/items", regexp.MustCompile(`^[A-Z]{2}\d+$`), func(w http.ResponseWriter, r *http.Request) error {
+		_, err := w.Write([]byte("regex " + r.PathValue("id")))
+		return err
+	})
+	// plain params at the same position are tried after the regex one
+	r.GET("/orders/{other}/items", func(w http.ResponseWriter, r *http.Request) error {
+		_, err := w.Write([]byte("plain " + r.PathValue("other")))
+		return err
+	})
+
+	tests := []struct {
+		path string
+		code int
+		body string
+		pre  int
+	}{
+		{"/orders/AB123/items", http.StatusOK, "regex AB123", 1},
+		{"/orders/ab123/items", http.StatusOK, "plain ab123", 1},
+		{"/orders/AB/items", http.StatusOK, "plain AB", 1},
+		{"/orders/AB123/nope", http.StatusNotFound, "", 0},
+	}
+
+	for _, test := range tests {
+		pre = 0
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, test.path, nil))
+		if rec.Code != test.code || rec.Body.String() != test.body || pre != test.pre {
+			t.Errorf("GET %s: got %d %q with %d middleware calls, want %d %q with %d", test.path, rec.Code, rec.Body.String(), pre, test.code, test.body, test.pre)
+		}
+	}
+
+	// the routes are checked by Validate like any other
+	r.HandleRegex(http.MethodGet, "/orders/{num}/items", regexp.MustCompile(`^[A-Z]{2}\d+$`), func(http.ResponseWriter, *http.Request) error { return nil })
+	if errs := r.Validate(); len(errs) != 1 {
+		t.Errorf("Validate() == %v, want the shadowed regex route", errs)
+	}
+
+	for _, pattern := range []string{"/orders", "/orders/{id}/{n}", "/orders/{id:\\d+}"} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("HandleRegex(%q) did not panic", pattern)
+				}
+			}()
+			r.HandleRegex(http.MethodGet, pattern, regexp.MustCompile(`.`), func(http.ResponseWriter, *http.Request) error { return nil })
+		}()
+	}
+}
+
+func TestRouterHandleLazy(t *testing.T) {
+	r := NewMux()
+
+	var calls atomic.Int32
+	r.HandleLazy(http.MethodGet, "/lazy", func() HandlerFunc {
+		calls.Add(1)
+		return func(w http.ResponseWriter, r *http.Request) error {
+			_, err := w.Write([]byte("lazy"))
+			return err
+		}
+	})
+
+	if calls.Load() != 0 {
+		t.Fatal("factory was called on registration")
+	}
+
+	var wg sync.WaitGroup
+	for range 32 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/lazy", nil))
+			if rec.Body.String() != "lazy" {
+				t.Errorf("got body %q, want %q", rec.Body.String(), "lazy")
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := calls.Load(); n != 1 {
+		t.Errorf("factory was called %d times, want 1", n)
+	}
+}
+
+func TestRouterUse(t *testing.T) {
+	r := NewMux()
+	r.GET("/", func(w http.ResponseWriter, r *http.Request) error {
+		_, err := w.Write([]byte("handler"))
+		return err
+	})
+
+	var order []string
+	r.Use(func(next HandlerFunc) HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) error {
+			order = append(order, "first")
+			return next(w, r)
+		}
+	}, func(next HandlerFunc) HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) error {
+			order = append(order, "second")
+			return next(w, r)
+		}
+	})
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if rec.Body.String() != "handler" {
+		t.Errorf("got body %q, want %q", rec.Body.String(), "handler")
+	}
+	if !slices.Equal(order, []string{"second", "first"}) {
+		t.Errorf("middleware ran in order %v, want [second first]", order)
+	}
+}
+
+func TestRouterPreMethod(t *testing.T) {
+	r := NewMux()
+
+	var ran []string
+	r.PreMethod(http.MethodPost, func(next HandlerFunc) HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) error {
+			ran = append(ran, r.Method+" "+r.URL.Path)
+			return next(w, r)
+		}
+	})
+
+	noop := func(http.ResponseWriter, *http.Request) error { return nil }
+	r.GET("/users", noop)
+	r.POST("/users", noop)
+	r.PUT("/users/{id}", noop)
+	r.POST("/users/{id}/ban", noop)
+
+	for _, req := range []struct{ method, path string }{
+		{http.MethodGet, "/users"},
+		{http.MethodPost, "/users"},
+		{http.MethodPut, "/users/1"},
+		{http.MethodPost, "/users/1/ban"},
+	} {
+		if rec := r.TestRequest(req.method, req.path, nil); rec.Code != http.StatusOK {
+			t.Errorf("%s %s: got %d, want %d", req.method, req.path, rec.Code, http.StatusOK)
+		}
+	}
+
+	if want := []string{"POST /users", "POST /users/1/ban"}; !slices.Equal(ran, want) {
+		t.Errorf("middleware ran for %v, want %v", ran, want)
+	}
+
+	if recv := catchPanic(func() { r.PreMethod("", func(next HandlerFunc) HandlerFunc { return next }) }); recv == nil {
+		t.Error("adding middleware for an empty method did not panic")
+	}
+}
+
+func TestRouterMiddlewareNames(t *testing.T) {
+	noop := func(next HandlerFunc) HandlerFunc { return next }
+
+	r := NewMux()
+	r.PreNamed("logger", noop)
+	r.Pre(noop)
+	r.PreMethod(http.MethodPost, noop)
+	r.PreNamed("csrf", CSRF(CSRFOptions{}))
+
+	if got := r.MiddlewareCount(); got != 4 {
+		t.Errorf("MiddlewareCount() == %d, want 4", got)
+	}
+	if got, want := r.MiddlewareNames(), []string{"logger", "", "", "csrf"}; !slices.Equal(got, want) {
+		t.Errorf("MiddlewareNames() == %q, want %q", got, want)
+	}
+}
+
+func TestRouterWildFirst(t *testing.T) {
+	r := NewMux()
+	r.GET("/x", func(w http.ResponseWriter, r *http.Request) error {
+		_, err := w.Write([]byte("get"))
+		return err
+	})
+	r.GET("/users/{id}", func(w http.ResponseWriter, r *http.Request) error {
+		_, err := w.Write([]byte("user"))
+		return err
+	})
+	r.ANY("/x", func(w http.ResponseWriter, r *http.Request) error {
+		_, err := w.Write([]byte("any"))
+		return err
+	})
+	r.ANY("/users/{id}/", func(w http.ResponseWriter, r *http.Request) error {
+		_, err := w.Write([]byte("any user"))
+		return err
+	})
+
+	tests := []struct {
+		wildFirst  bool
+		path, want string
+		code       int
+	}{
+		{false, "/x", "get", http.StatusOK},
+		{true, "/x", "any", http.StatusOK},
+		// the trailing slash redirect of the ANY route doesn't get in the way
+		{true, "/users/1", "user", http.StatusOK},
+		{true, "/users/1/", "any user", http.StatusOK},
+	}
+
+	for _, test := range tests {
+		r.WildFirst = test.wildFirst
+
+		rec := r.TestRequest(http.MethodGet, test.path, nil)
+		if rec.Code != test.code || rec.Body.String() != test.want {
+			t.Errorf("WildFirst %v, GET %s: got %d %q, want %d %q", test.wildFirst, test.path, rec.Code, rec.Body.String(), test.code, test.want)
+		}
+	}
+}
+
+func TestRouterMalformedEscapes(t *testing.T) {
+	r := NewMux()
+	r.GET("/files/{name}", func(w http.ResponseWriter, r *http.Request) error {
+		_, err := w.Write([]byte(r.PathValue("name")))
+		return err
+	})
+
+	tests := []struct {
+		rawPath string
+		code    int
+	}{
+		{"/files/a%zz", http.StatusBadRequest},
+		{"/files/a%2", http.StatusBadRequest},
+		{"/files/a%2F", http.StatusOK},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/files/a", nil)
+		req.URL.RawPath = test.rawPath
+
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		if rec.Code != test.code {
+			t.Errorf("GET %s: got %d, want %d", test.rawPath, rec.Code, test.code)
+		}
+	}
+
+	// servers reject them before routing
+	s := httptest.NewServer(r)
+	defer s.Close()
+
+	conn, err := net.Dial("tcp", s.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	fmt.Fprint(conn, "GET /files/a%zz HTTP/1.1\r\nHost: localhost\r\n\r\n")
+	res, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+
+	if res.StatusCode != http.StatusBadRequest {
+		t.Errorf("GET /files/a%%zz: got %d, want %d", res.StatusCode, http.StatusBadRequest)
+	}
+}
+
+func TestRouterHandleAll(t *testing.T) {
+	tag := func(name string) func(HandlerFunc) HandlerFunc {
+		return func(next HandlerFunc) HandlerFunc {
+			return func(w http.ResponseWriter, r *http.Request) error {
+				w.Header().Add("X-Middleware", name)
+				return next(w, r)
+			}
+		}
+	}
+	write := func(body string) HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) error {
+			_, err := w.Write([]byte(body))
+			return err
+		}
+	}
+
+	r := NewMux()
+	r.Pre(tag("global"))
+	r.HandleAll([]RouteSpec{
+		{Method: http.MethodGet, Path: "/users", Handler: write("list")},
+		{Method: http.MethodPost, Path: "/users", Handler: write("create"), Middleware: []func(HandlerFunc) HandlerFunc{tag("auth")}},
+		{Method: http.MethodDelete, Path: "/users/{id}", Handler: write("delete"), Middleware: []func(HandlerFunc) HandlerFunc{tag("auth"), tag("audit")}},
+	})
+
+	tests := []struct {
+		method, path, body string
+		middleware         []string
+	}{
+		{http.MethodGet, "/users", "list", []string{"global"}},
+		{http.MethodPost, "/users", "create", []string{"global", "auth"}},
+		{http.MethodDelete, "/users/1", "delete", []string{"global", "audit", "auth"}},
+	}
+
+	for _, test := range tests {
+		rec := r.TestRequest(test.method, test.path, nil)
+		if rec.Body.String() != test.body {
+			t.Errorf("%s %s: got body %q, want %q", test.method, test.path, rec.Body.String(), test.body)
+		}
+		if got := rec.Header().Values("X-Middleware"); !slices.Equal(got, test.middleware) {
+			t.Errorf("%s %s: middleware ran in order %v, want %v", test.method, test.path, got, test.middleware)
+		}
+	}
+
+	if recv := catchPanic(func() { r.HandleAll([]RouteSpec{{Method: http.MethodGet, Path: "/nil"}}) }); recv == nil {
+		t.Error("registering a spec without a handler did not panic")
+	}
+}
+
+func TestRouterChain(t *testing.T) {
+	write := func(body string) HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) error {
+			_, err := w.Write([]byte(body))
+			return err
+		}
+	}
+
+	r1, r2, r3 := NewMux(), NewMux(), NewMux()
+	r1.GET("/one", write("one"))
+	r2.GET("/two", write("two"))
+	r3.GET("/three", write("three"))
+	r3.GET("/one", write("shadowed"))
+
+	r1.Chain(r2, r3, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}))
+
+	tests := []struct {
+		path string
+		code int
+		body string
+	}{
+		{"/one", http.StatusOK, "one"},
+		{"/two", http.StatusOK, "two"},
+		{"/three", http.StatusOK, "three"},
+		{"/four", http.StatusTeapot, ""},
+	}
+
+	for _, test := range tests {
+		rec := r1.TestRequest(http.MethodGet, test.path, nil)
+		if rec.Code != test.code || rec.Body.String() != test.body {
+			t.Errorf("GET %s: got %d %q, want %d %q", test.path, rec.Code, rec.Body.String(), test.code, test.body)
+		}
+	}
+
+	// the chained muxes are left as is
+	if rec := r2.TestRequest(http.MethodGet, "/three", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("GET /three on the chained mux: got %d, want %d", rec.Code, http.StatusNotFound)
+	}
+
+	// other handlers pass requests on by responding with 404
+	r4 := NewMux()
+	r4.Chain(http.NotFoundHandler(), r3)
+	if rec := r4.TestRequest(http.MethodGet, "/three", nil); rec.Code != http.StatusOK || rec.Body.String() != "three" {
+		t.Errorf("GET /three past a non-Mux handler: got %d %q, want 200 %q", rec.Code, rec.Body.String(), "three")
+	}
+	if rec := r4.TestRequest(http.MethodGet, "/four", nil); rec.Code != http.StatusNotFound || rec.Header().Get("X-Content-Type-Options") != "" {
+		t.Errorf("GET /four past a non-Mux handler: got %d with header %v, want 404 without the discarded one", rec.Code, rec.Header())
+	}
+}
+
+func TestRouterReset(t *testing.T) {
+	r := NewMux()
+	r.Pre(func(next HandlerFunc) HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) error {
+			w.Header().Set("X-Middleware", "yes")
+			return next(w, r)
+		}
+	})
+
+	noop := func(http.ResponseWriter, *http.Request) error { return nil }
+	r.GET("/users", noop)
+	r.GET("/users/{id}", noop)
+	r.Handle("BREW", "/coffee", noop)
+	r.ANY("/any", noop)
+
+	r.Reset()
+
+	for _, req := range []struct{ method, path string }{
+		{http.MethodGet, "/users"},
+		{http.MethodGet, "/users/1"},
+		{"BREW", "/coffee"},
+		{http.MethodGet, "/any"},
+	} {
+		if rec := r.TestRequest(req.method, req.path, nil); rec.Code != http.StatusNotFound {
+			t.Errorf("%s %s: got %d after Reset, want %d", req.method, req.path, rec.Code, http.StatusNotFound)
+		}
+	}
+	if routes := r.ListOrdered(); len(routes) != 0 {
+		t.Errorf("ListOrdered() == %v after Reset, want none", routes)
+	}
+
+	// routes can be registered again, with the middleware still applied
+	r.GET("/users", noop)
+	rec := r.TestRequest(http.MethodGet, "/users", nil)
+	if rec.Code != http.StatusOK || rec.Header().Get("X-Middleware") != "yes" {
+		t.Errorf("GET /users: got %d, X-Middleware %q, want 200 and %q", rec.Code, rec.Header().Get("X-Middleware"), "yes")
+	}
+}
+
+func TestRouterRoutesHandler(t *testing.T) {
+	noop := func(http.ResponseWriter, *http.Request) error { return nil }
+
+	r := NewMux()
+	r.GET("/users/{id}", noop)
+	r.POST("/users", noop)
+	r.GET("/debug/routes", r.RoutesHandler())
+
+	rec := r.TestRequest(http.MethodGet, "/debug/routes", nil)
+	want := `[{"method":"GET","path":"/users/{id}"},{"method":"GET","path":"/debug/routes"},{"method":"POST","path":"/users"}]` + "\n"
+	if rec.Code != http.StatusOK || rec.Body.String() != want {
+		t.Errorf("got %d %q, want 200 %q", rec.Code, rec.Body.String(), want)
+	}
+	if got := rec.Header().Get("Content-Type"); got != "application/json" {
+		t.Errorf("Content-Type == %q, want %q", got, "application/json")
+	}
+
+	r.DisableRoutesHandler = true
+	if rec := r.TestRequest(http.MethodGet, "/debug/routes", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("disabled: got %d, want %d", rec.Code, http.StatusNotFound)
+	}
+}
+
+func TestRouterHandleTagged(t *testing.T) {
+	noop := func(http.ResponseWriter, *http.Request) error { return nil }
+
+	tags := map[string]string{"summary": "Deletes a user", "auth": "required"}
+
+	r := NewMux()
+	r.HandleTagged(http.MethodDelete, "/users/{id}", noop, tags)
+	r.HandleTagged(http.MethodGet, "/health", noop, nil)
+	r.GET("/debug/routes", r.RoutesHandler())
+
+	tags["auth"] = "none"
+	if got := r.RouteTags(http.MethodDelete, "/users/{id}"); !maps.Equal(got, map[string]string{"summary": "Deletes a user", "auth": "required"}) {
+		t.Errorf("RouteTags(DELETE /users/{id}) == %v, want the registered tags", got)
+	}
+	for _, route := range [][2]string{{http.MethodGet, "/health"}, {http.MethodGet, "/users/{id}"}} {
+		if got := r.RouteTags(route[0], route[1]); got != nil {
+			t.Errorf("RouteTags(%s %s) == %v, want nil", route[0], route[1], got)
+		}
+	}
+
+	if rec := r.TestRequest(http.MethodDelete, "/users/1", nil); rec.Code != http.StatusOK {
+		t.Errorf("DELETE /users/1: got %d, want %d", rec.Code, http.StatusOK)
+	}
+
+	rec := r.TestRequest(http.MethodGet, "/debug/routes", nil)
+	want := `[{"method":"GET","path":"/health"},{"method":"GET","path":"/debug/routes"},{"method":"DELETE","path":"/users/{id}","tags":{"auth":"required","summary":"Deletes a user"}}]` + "\n"
+	if rec.Body.String() != want {
+		t.Errorf("GET /debug/routes: got %q, want %q", rec.Body.String(), want)
+	}
+}
+
+func TestRouterDefaultContentType(t *testing.T) {
+	r := NewMux()
+	r.DefaultContentType = "application/json"
+	r.GET("/default", func(w http.ResponseWriter, r *http.Request) error {
+		_, err := w.Write([]byte(`{"ok":true}`))
+		return err
+	})
+	r.GET("/explicit", func(w http.ResponseWriter, r *http.Request) error {
+		w.Header().Set("Content-Type", "text/csv")
+		_, err := w.Write([]byte("a,b"))
+		return err
+	})
+	r.GET("/created", func(w http.ResponseWriter, r *http.Request) error {
+		w.WriteHeader(http.StatusCreated)
+		_, err := w.Write([]byte(`{}`))
+		return err
+	})
+	r.GET("/empty", func(w http.ResponseWriter, r *http.Request) error {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	})
+
+	tests := []struct {
+		path, want string
+	}{
+		{"/default", "application/json"},
+		{"/explicit", "text/csv"},
+		{"/created", "application/json"},
+		{"/empty", ""},
+	}
+
+	for _, test := range tests {
+		rec := r.TestRequest(http.MethodGet, test.path, nil)
+		if got := rec.Header().Get("Content-Type"); got != test.want {
+			t.Errorf("GET %s: Content-Type == %q, want %q", test.path, got, test.want)
+		}
+	}
+}
+
+func TestRouterMaxConcurrent(t *testing.T) {
+	r := NewMux()
+	r.MaxConcurrent = 2
+
+	started, release := make(chan struct{}), make(chan struct{})
+	r.GET("/slow", func(w http.ResponseWriter, r *http.Request) error {
+		started <- struct{}{}
+		<-release
+		return nil
+	})
+	r.GET("/panic", func(w http.ResponseWriter, r *http.Request) error {
+		panic("boom")
+	})
+	r.OnPanic = func(w http.ResponseWriter, r *http.Request, a any) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+
+	var wg sync.WaitGroup
+	codes := make([]int, 2)
+	for i := range codes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			codes[i] = r.TestRequest(http.MethodGet, "/slow", nil).Code
+		}()
+		<-started
+	}
+
+	rec := r.TestRequest(http.MethodGet, "/slow", nil)
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") != "1" {
+		t.Errorf("saturated: got %d with Retry-After %q, want %d with %q", rec.Code, rec.Header().Get("Retry-After"), http.StatusServiceUnavailable, "1")
+	}
+
+	close(release)
+	wg.Wait()
+	for _, code := range codes {
+		if code != http.StatusOK {
+			t.Errorf("admitted request: got %d, want %d", code, http.StatusOK)
+		}
+	}
+
+	// panics release their slot
+	for i := 0; i < 3; i++ {
+		r.TestRequest(http.MethodGet, "/panic", nil)
+	}
+	go func() { <-started }()
+	if rec := r.TestRequest(http.MethodGet, "/slow", nil); rec.Code != http.StatusOK {
+		t.Errorf("after panics: got %d, want %d", rec.Code, http.StatusOK)
+	}
+}
+
+func TestRouterListOrdered(t *testing.T) {
+	noop := func(http.ResponseWriter, *http.Request) error { return nil }
+
+	r := NewMux()
+	r.POST("/users", noop)
+	r.GET("/users/{id?}", noop)
+	r.DELETE("/users/{id}", noop)
+	r.GET("/health", noop)
+
+	v1 := NewMux()
+	v1.PATCH("/users/{id}", noop)
+	r.Merge("/v1", v1)
+
+	want := []Route{
+		{http.MethodPost, "/users"},
+		{http.MethodGet, "/users/{id?}"},
+		{http.MethodDelete, "/users/{id}"},
+		{http.MethodGet, "/health"},
+		{http.MethodPatch, "/v1/users/{id}"},
+	}
+	if got := r.ListOrdered(); !slices.Equal(got, want) {
+		t.Errorf("ListOrdered() == %v, want %v", got, want)
+	}
+}
+
+func TestRouterRoutes(t *testing.T) {
+	noop := func(http.ResponseWriter, *http.Request) error { return nil }
+
+	r := NewMux()
+	r.GET("/show/{name?}", noop)
+	r.GET(`/users/{id:\d+}/info`, noop)
+	r.POST("/files/{filepath:*}", noop)
+
+	want := []Route{
+		{http.MethodGet, "/show/{name?}"},
+		{http.MethodGet, `/users/{id:\d+}/info`},
+		{http.MethodPost, "/files/{filepath:*}"},
+	}
+	if got := r.Routes(); !slices.Equal(got, want) {
+		t.Errorf("Routes() == %v, want %v", got, want)
+	}
+
+	r = NewMux()
+	r.MergeTrailingSlash = true
+	r.GET("/show/{name?}", noop)
+
+	want = []Route{{http.MethodGet, "/show/{name?}"}}
+	if got := r.Routes(); !slices.Equal(got, want) {
+		t.Errorf("Routes() with merged trailing slashes == %v, want %v", got, want)
+	}
+}
+
+func TestRouterStats(t *testing.T) {
+	noop := func(http.ResponseWriter, *http.Request) error { return nil }
+
+	r := NewMux()
+	r.GET("/users/{id}", noop)
+	r.GET("/files/{filepath:*}", noop)
+	r.POST("/users", noop)
+
+	stats := r.Stats()
+	if len(stats) != 2 {
+		t.Fatalf("Stats() == %+v, want stats of 2 methods", stats)
+	}
+	if got := stats[http.MethodGet]; got.Params != 1 || got.Wildcards != 1 {
+		t.Errorf("Stats()[GET] == %+v, want 1 param and 1 wildcard", got)
+	}
+	if got := stats[http.MethodPost]; got.Params != 0 || got.Wildcards != 0 {
+		t.Errorf("Stats()[POST] == %+v, want no params nor wildcards", got)
+	}
+}
+
+func TestRouterFallback(t *testing.T) {
+	r := NewMux()
+
+	var calls int
+	r.Pre(func(next HandlerFunc) HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) error {
+			calls++
+			return next(w, r)
+		}
+	})
+	r.GET("/", func(w http.ResponseWriter, r *http.Request) error { return nil })
+	r.Fallback(func(w http.ResponseWriter, r *http.Request) error {
+		return &HTTPError{Code: http.StatusGone, Err: errors.New("gone for good")}
+	})
+
+	admin := r.Group("/admin")
+	admin.OnNotFound(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+
+	rec := r.TestRequest(http.MethodGet, "/nope", nil)
+	if rec.Code != http.StatusGone || !strings.Contains(rec.Body.String(), "gone for good") {
+		t.Errorf("GET /nope: got %d %q, want 410 \"gone for good\"", rec.Code, rec.Body.String())
+	}
+	if calls != 1 {
+		t.Errorf("middleware ran %d times, want 1", calls)
+	}
+
+	if rec := r.TestRequest(http.MethodPost, "/", nil); rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST /: got %d, want %d", rec.Code, http.StatusMethodNotAllowed)
+	}
+	if rec := r.TestRequest(http.MethodGet, "/admin/nope", nil); rec.Code != http.StatusTeapot {
+		t.Errorf("GET /admin/nope: got %d, want %d", rec.Code, http.StatusTeapot)
+	}
+}
+
+func TestRouterAllowedMethodWild(t *testing.T) {
+	r := NewMux()
+	r.ANY("/thing", func(w http.ResponseWriter, r *http.Request) error { return nil })
+	r.GET("/thing", func(w http.ResponseWriter, r *http.Request) error { return nil })
+	r.POST("/other", func(w http.ResponseWriter, r *http.Request) error { return nil })
+
+	all := "CONNECT, DELETE, GET, HEAD, OPTIONS, PATCH, POST, PUT, TRACE"
+
+	rec := r.TestRequest(http.MethodOptions, "/thing", nil)
+	if got := strings.Join(rec.Header()["Allow"], ", "); got != all {
+		t.Errorf("OPTIONS /thing: Allow == %q, want %q", got, all)
+	}
+
+	req := httptest.NewRequest(http.MethodOptions, "*", nil)
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	if got := strings.Join(rec.Header()["Allow"], ", "); got != all {
+		t.Errorf("OPTIONS *: Allow == %q, want %q", got, all)
+	}
+}
+
+func TestRouterMount(t *testing.T) {
+	sub := NewMux()
+	sub.OnNotFound = func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}
+
+	r := NewMux()
+	r.Mount("/orgs/{org}", sub)
+
+	// added after mounting
+	sub.GET("/users/{id}", func(w http.ResponseWriter, r *http.Request) error {
+		_, err := fmt.Fprintf(w, "%s %s %s", r.URL.Path, r.PathValue("org"), r.PathValue("id"))
+		return err
+	})
+	sub.GET("/", func(w http.ResponseWriter, r *http.Request) error {
+		_, err := w.Write([]byte("root"))
+		return err
+	})
+
+	tests := []struct {
+		path string
+		code int
+		body string
+	}{
+		{"/orgs/acme/users/42", http.StatusOK, "/users/42 acme 42"},
+		{"/orgs/acme", http.StatusOK, "root"},
+		{"/orgs/acme/", http.StatusOK, "root"},
+		{"/orgs/acme/nope", http.StatusTeapot, ""},
+	}
+
+	for _, test := range tests {
+		rec := r.TestRequest(http.MethodGet, test.path, nil)
+		if rec.Code != test.code || rec.Body.String() != test.body {
+			t.Errorf("GET %s: got %d %q, want %d %q", test.path, rec.Code, rec.Body.String(), test.code, test.body)
+		}
+	}
+}
+
+func TestRouterMountRoute(t *testing.T) {
+	var subRoute, route string
+
+	sub := NewMux()
+	sub.Use(func(next HandlerFunc) HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) error {
+			err := next(w, r)
+			rw, _ := AsResponseWriter(w)
+			subRoute = rw.Route()
+			return err
+		}
+	})
+	sub.GET("/users/{id}", func(w http.ResponseWriter, r *http.Request) error { return nil })
+	sub.GET("/", func(w http.ResponseWriter, r *http.Request) error { return nil })
+
+	r := NewMux()
+	r.Use(func(next HandlerFunc) HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) error {
+			err := next(w, r)
+			rw, _ := AsResponseWriter(w)
+			route = rw.Route()
+			return err
+		}
+	})
+	r.GET("/health", func(w http.ResponseWriter, r *http.Request) error { return nil })
+	r.Mount("/orgs/{org}", sub)
+
+	root := NewMux()
+	root.Use(r.use...)
+	root.GET("/health", func(w http.ResponseWriter, r *http.Request) error { return nil })
+	root.Mount("/", sub)
+
+	tests := []struct {
+		mux   *Mux
+		path  string
+		code  int
+		route string
+	}{
+		{r, "/orgs/acme/users/42", http.StatusOK, "/orgs/{org}/users/{id}"},
+		{r, "/orgs/acme", http.StatusOK, "/orgs/{org}"},
+		{r, "/health", http.StatusOK, "/health"},
+		{root, "/users/42", http.StatusOK, "/users/{id}"},
+		{root, "/", http.StatusOK, "/"},
+		{root, "/health", http.StatusOK, "/health"},
+		{root, "/nope", http.StatusNotFound, ""},
+	}
+
+	for _, test := range tests {
+		subRoute, route = "", ""
+		rec := test.mux.TestRequest(http.MethodGet, test.path, nil)
+		if rec.Code != test.code {
+			t.Errorf("GET %s: got %d, want %d", test.path, rec.Code, test.code)
+		}
+		if route != test.route {
+			t.Errorf("GET %s: route == %q, want %q", test.path, route, test.route)
+		}
+		if test.route != "/health" && subRoute != test.route {
+			t.Errorf("GET %s: route within the mounted mux == %q, want %q", test.path, subRoute, test.route)
+		}
+	}
+}
+
+func TestGroupMount(t *testing.T) {
+	sub := NewMux()
+
+	r := NewMux()
+	api := r.Group("/api")
+	api.Group("/v1").Mount("/orgs/{org}", sub)
+
+	// added after mounting
+	sub.GET("/users/{id}", func(w http.ResponseWriter, r *http.Request) error {
+		_, err := fmt.Fprintf(w, "%s %s %s", r.URL.Path, r.PathValue("org"), r.PathValue("id"))
+		return err
+	})
+
+	rec := r.TestRequest(http.MethodGet, "/api/v1/orgs/acme/users/42", nil)
+	if want := "/users/42 acme 42"; rec.Code != http.StatusOK || rec.Body.String() != want {
+		t.Errorf("got %d %q, want 200 %q", rec.Code, rec.Body.String(), want)
+	}
+
+	if rec := r.TestRequest(http.MethodGet, "/orgs/acme/users/42", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("outside of the group: got %d, want %d", rec.Code, http.StatusNotFound)
+	}
+}
+
+func TestRouterOnRedirect(t *testing.T) {
+	r := NewMux()
+	r.GET("/users", func(w http.ResponseWriter, r *http.Request) error { return nil })
+	r.POST("/users", func(w http.ResponseWriter, r *http.Request) error { return nil })
+
+	var to string
+	var code int
+	r.OnRedirect = func(w http.ResponseWriter, r *http.Request, redirectTo string, redirectCode int) {
+		to, code = redirectTo, redirectCode
+	}
+
+	tests := []struct {
+		method, path string
+		to           string
+		code         int
+	}{
+		{http.MethodGet, "/users/?page=2", "/users?page=2", http.StatusMovedPermanently},
+		{http.MethodPost, "/USERS", "/users", http.StatusPermanentRedirect},
+	}
+
+	for _, test := range tests {
+		to, code = "", 0
+		rec := r.TestRequest(test.method, test.path, nil)
+
+		if to != test.to || code != test.code {
+			t.Errorf("%s %s: OnRedirect got %q %d, want %q %d", test.method, test.path, to, code, test.to, test.code)
+		}
+		if rec.Code != test.code || rec.Header().Get("Location") != test.to {
+			t.Errorf("%s %s: got %d to %q, want %d to %q", test.method, test.path, rec.Code, rec.Header().Get("Location"), test.code, test.to)
+		}
+	}
+}
+
+func TestRouterGo(t *testing.T) {
+	r := NewMux()
+
+	recovered := make(chan any, 1)
+	r.OnBackgroundPanic = func(recv any) {
+		recovered <- recv
+	}
+
+	r.Go(func() {
+		panic("background")
+	})
+
+	select {
+	case recv := <-recovered:
+		if recv != "background" {
+			t.Errorf("OnBackgroundPanic got %v, want %q", recv, "background")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("OnBackgroundPanic was not called")
+	}
+}
+
+func TestRouterMaxPath(t *testing.T) {
+	r := NewMux()
+	r.MaxPathLength = 32
+	r.MaxPathSegments = 4
+	r.GET("/{path:*}", func(w http.ResponseWriter, r *http.Request) error { return nil })
+
+	tests := []struct {
+		path string
+		code int
+	}{
+		{"/a/b/c/d", http.StatusOK},
+		{"/" + strings.Repeat("a", 31), http.StatusOK},
+		{"/" + strings.Repeat("a", 32), http.StatusRequestURITooLong},
+		{"/a/b/c/d/e", http.StatusRequestURITooLong},
+	}
+
+	for _, test := range tests {
+		if rec := r.TestRequest(http.MethodGet, test.path, nil); rec.Code != test.code {
+			t.Errorf("GET %s: got %d, want %d", test.path, rec.Code, test.code)
+		}
+	}
+}
+
+func TestRouterOPTIONSPerRoute(t *testing.T) {
+	noop := func(http.ResponseWriter, *http.Request) error { return nil }
+
+	r := NewMux()
+	r.GET("/items/{id}", noop)
+	r.DELETE("/items/{id}", noop)
+	r.OPTIONS("/items/{id}", func(w http.ResponseWriter, r *http.Request) error {
+		w.Header().Set("X-Item", r.PathValue("id"))
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	})
+	r.GET("/items/{id}/tags", noop)
+	r.POST("/items/{id}/tags", noop)
+
+	rec := r.TestRequest(http.MethodOptions, "/items/42", nil)
+	if rec.Code != http.StatusNoContent || rec.Header().Get("X-Item") != "42" {
+		t.Errorf("OPTIONS /items/42: got %d with X-Item %q, want the custom handler", rec.Code, rec.Header().Get("X-Item"))
+	}
+	if allow := rec.Header().Get("Allow"); allow != "" {
+		t.Errorf("OPTIONS /items/42: Allow == %q, want none set automatically", allow)
+	}
+
+	rec = r.TestRequest(http.MethodOptions, "/items/42/tags", nil)
+	if got := strings.Join(rec.Header()["Allow"], ", "); rec.Code != http.StatusOK || got != "GET, OPTIONS, POST" {
+		t.Errorf("OPTIONS /items/42/tags: got %d with Allow %q, want 200 with %q", rec.Code, got, "GET, OPTIONS, POST")
+	}
+}
+
+func TestRouterDuplicateParamNames(t *testing.T) {
+	noop := func(http.ResponseWriter, *http.Request) error { return nil }
+
+	r := NewMux()
+	if recv := catchPanic(func() { r.GET("/{id}/{id}", noop) }); recv == nil {
+		t.Error("registering /{id}/{id} did not panic")
+	}
+	if recv := catchPanic(func() { r.GET("/{a}/{b}", noop) }); recv != nil {
+		t.Errorf("registering /{a}/{b} panicked: %v", recv)
+	}
+}
+
+func TestRouterHandleMany(t *testing.T) {
+	r := NewMux()
+
+	var wrapped int
+	r.Pre(func(next HandlerFunc) HandlerFunc {
+		wrapped++
+		return next
+	})
+	r.HandleMany(http.MethodGet, []string{"/about", "/about-us", "/{lang}/about"}, func(w http.ResponseWriter, r *http.Request) error {
+		_, err := w.Write([]byte("about " + r.PathValue("lang")))
+		return err
+	})
+
+	if wrapped != 1 {
+		t.Errorf("middleware wrapped the handler %d times, want 1", wrapped)
+	}
+
+	tests := []struct {
+		path, body string
+	}{
+		{"/about", "about "},
+		{"/about-us", "about "},
+		{"/de/about", "about de"},
+	}
+
+	for _, test := range tests {
+		if rec := r.TestRequest(http.MethodGet, test.path, nil); rec.Code != http.StatusOK || rec.Body.String() != test.body {
+			t.Errorf("GET %s: got %d %q, want 200 %q", test.path, rec.Code, rec.Body.String(), test.body)
+		}
+	}
+}
+
+func BenchmarkRouterServeHTTPStatic(b *testing.B) {
+	handler := func(http.ResponseWriter, *http.Request) error { return nil }
+
+	r := NewMux()
+	var paths []string
+	for _, resource := range []string{"users", "posts", "comments", "orders", "invoices", "products", "carts", "reviews"} {
+		for _, action := range []string{"list", "search", "export", "stats", "recent", "archived"} {
+			path := "/api/v1/" + resource + "/" + action
+			paths = append(paths, path)
+			r.GET(path, handler)
+		}
+		r.GET("/api/v1/"+resource+"/{id}", handler)
+	}
+
+	reqs := make([]*http.Request, len(paths))
+	for i, path := range paths {
+		reqs[i] = httptest.NewRequest(http.MethodGet, path, nil)
+	}
+
+	// the same routes matched by traversing the tree only
+	tree := r.Clone()
+	tree.statics = nil
+
+	for name, r := range map[string]*Mux{"index": r, "tree": tree} {
+		b.Run(name, func(b *testing.B) {
+			rec := httptest.NewRecorder()
+
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				r.ServeHTTP(rec, reqs[i%len(reqs)])
+			}
+		})
+	}
+}
+
+func TestRouterStaticIndex(t *testing.T) {
+	route := func(name string) HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) error {
+			_, err := w.Write([]byte(name + r.PathValue("id")))
+			return err
+		}
+	}
+
+	r := NewMux()
+	r.GET("/users/me", route("me"))
+	r.GET("/users/{id}", route("user"))
+	r.GET("/users", route("users"))
+	r.POST("/users", route("create"))
+	r.ANY("/any", route("any"))
+
+	tests := []struct {
+		method, path string
+		code         int
+		body         string
+	}{
+		{http.MethodGet, "/users/me", http.StatusOK, "me"},
+		{http.MethodGet, "/users/42", http.StatusOK, "user42"},
+		{http.MethodGet, "/users", http.StatusOK, "users"},
+		{http.MethodPost, "/users", http.StatusOK, "create"},
+		{http.MethodGet, "/users/", http.StatusMovedPermanently, ""},
+		{http.MethodDelete, "/users", http.StatusMethodNotAllowed, ""},
+		{http.MethodGet, "/any", http.StatusOK, "any"},
+	}
+
+	for _, test := range tests {
+		rec := r.TestRequest(test.method, test.path, nil)
+		if rec.Code != test.code || test.body != "" && rec.Body.String() != test.body {
+			t.Errorf("%s %s: got %d %q, want %d %q", test.method, test.path, rec.Code, rec.Body.String(), test.code, test.body)
+		}
+	}
+}
+
+func TestRouterRequestTimeout(t *testing.T) {
+	r := NewMux()
+	r.RequestTimeout = 10 * time.Millisecond
+
+	r.GET("/slow", func(w http.ResponseWriter, r *http.Request) error {
+		select {
+		case <-time.After(time.Second):
+			_, err := w.Write([]byte("done"))
+			return err
+		case <-r.Context().Done():
+			return r.Context().Err()
+		}
+	})
+	r.GET("/ignoring", func(w http.ResponseWriter, r *http.Request) error {
+		time.Sleep(20 * time.Millisecond)
+		return nil
+	})
+	r.GET("/fast", func(w http.ResponseWriter, r *http.Request) error {
+		if _, ok := r.Context().Deadline(); !ok {
+			t.Error("request context has no deadline")
+		}
+		return nil
+	})
+
+	start := time.Now()
+	if rec := r.TestRequest(http.MethodGet, "/slow", nil); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("GET /slow: got %d, want %d", rec.Code, http.StatusServiceUnavailable)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("GET /slow took %v, want it cut off", elapsed)
+	}
+
+	if rec := r.TestRequest(http.MethodGet, "/ignoring", nil); rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") != "1" {
+		t.Errorf("GET /ignoring: got %d with Retry-After %q, want %d with %q", rec.Code, rec.Header().Get("Retry-After"), http.StatusServiceUnavailable, "1")
+	}
+	if rec := r.TestRequest(http.MethodGet, "/fast", nil); rec.Code != http.StatusOK {
+		t.Errorf("GET /fast: got %d, want %d", rec.Code, http.StatusOK)
+	}
+}
+
+func TestDelegate(t *testing.T) {
+	backends := map[string]http.Handler{
+		"a": http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusAccepted)
+			_, _ = w.Write([]byte("backend a"))
+		}),
+		"panic": http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			panic("backend panic")
+		}),
+	}
+
+	var recovered any
+	r := NewMux()
+	r.OnError = func(w http.ResponseWriter, r *http.Request, err error) {
+		t.Errorf("OnError got %v, want no error", err)
+	}
+	r.OnPanic = func(w http.ResponseWriter, r *http.Request, a any) {
+		recovered = a
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+	r.GET("/{backend}", func(w http.ResponseWriter, r *http.Request) error {
+		backend, ok := backends[r.PathValue("backend")]
+		if !ok {
+			return Abort(http.StatusNotFound, "")
+		}
+		return Delegate(backend)(w, r)
+	})
+
+	if rec := r.TestRequest(http.MethodGet, "/a", nil); rec.Code != http.StatusAccepted || rec.Body.String() != "backend a" {
+		t.Errorf("GET /a: got %d %q, want %d %q", rec.Code, rec.Body.String(), http.StatusAccepted, "backend a")
+	}
+
+	if rec := r.TestRequest(http.MethodGet, "/panic", nil); rec.Code != http.StatusInternalServerError || recovered != "backend panic" {
+		t.Errorf("GET /panic: got %d with %v recovered, want %d with %q", rec.Code, recovered, http.StatusInternalServerError, "backend panic")
+	}
+}
+
+func TestOnRegister(t *testing.T) {
+	var registered []string
+
+	r := NewMux()
+	r.OnRegister = func(method, path string) {
+		registered = append(registered, method+" "+path)
+	}
+
+	noop := func(w http.ResponseWriter, r *http.Request) error { return nil }
+	r.GET("/users", noop)
+	r.GET("/posts/{year?}/{month?}", noop)
+	r.HandleMany(http.MethodPost, []string{"/a", "/b"}, noop)
+
+	want := []string{
+		"GET /users",
+		"GET /posts",
+		"GET /posts/{year}",
+		"GET /posts/{year}/{month}",
+		"POST /a",
+		"POST /b",
+	}
+	if !slices.Equal(registered, want) {
+		t.Errorf("registered %q, want %q", registered, want)
+	}
+}
+
+func TestStripPrefix(t *testing.T) {
+	var gotPath, gotRawPath string
+	h := StripPrefix("/api", func(w http.ResponseWriter, r *http.Request) error {
+		gotPath, gotRawPath = r.URL.Path, r.URL.RawPath
+		return nil
+	})
+
+	tests := []struct {
+		path          string
+		err           bool
+		stripped, raw string
+	}{
+		{"/api/users", false, "/users", ""},
+		{"/api/files/a%2Fb", false, "/files/a/b", "/files/a%2Fb"},
+		{"/api", false, "", ""},
+		{"/other/users", true, "", ""},
+	}
+
+	for _, test := range tests {
+		gotPath, gotRawPath = "", ""
+		req := httptest.NewRequest(http.MethodGet, test.path, nil)
+		orig := req.URL.Path
+
+		err := h(httptest.NewRecorder(), req)
+		if test.err {
+			if statusOf(err) != http.StatusNotFound {
+				t.Errorf("GET %s: got error %v, want 404", test.path, err)
+			}
+			continue
+		}
+		if err != nil || gotPath != test.stripped || gotRawPath != test.raw {
+			t.Errorf("GET %s: got %q %q with error %v, want %q %q", test.path, gotPath, gotRawPath, err, test.stripped, test.raw)
+		}
+		if req.URL.Path != orig {
+			t.Errorf("GET %s: the original request was modified", test.path)
+		}
+	}
+}
+
+func TestAllowHeaderOrder(t *testing.T) {
+	noop := func(w http.ResponseWriter, r *http.Request) error { return nil }
+
+	tests := []struct {
+		order    AllowOrder
+		hideHEAD bool
+		want     string
+	}{
+		{AllowSorted, false, "DELETE, GET, HEAD, OPTIONS, POST"},
+		{AllowDeclaration, false, "POST, GET, HEAD, DELETE, OPTIONS"},
+		{AllowSorted, true, "DELETE, GET, OPTIONS, POST"},
+		{AllowDeclaration, true, "POST, GET, DELETE, OPTIONS"},
+	}
+
+	for _, test := range tests {
+		r := NewMux()
+		r.AllowHeaderOrder = test.order
+		r.AllowHeaderHideHEAD = test.hideHEAD
+		r.POST("/users", noop)
+		r.GET("/users", noop)
+		r.HEAD("/users", noop)
+		r.DELETE("/users", noop)
+		r.HEAD("/head", noop)
+
+		for _, method := range []string{http.MethodOptions, http.MethodPut} {
+			rec := r.TestRequest(method, "/users", nil)
+			if got := strings.Join(rec.Header().Values("Allow"), ", "); got != test.want {
+				t.Errorf("order %d, hide HEAD %t, %s /users: Allow == %q, want %q", test.order, test.hideHEAD, method, got, test.want)
+			}
+		}
+
+		rec := r.TestRequest(http.MethodOptions, "*", nil)
+		if got := strings.Join(rec.Header().Values("Allow"), ", "); got != test.want {
+			t.Errorf("order %d, hide HEAD %t, OPTIONS *: Allow == %q, want %q", test.order, test.hideHEAD, got, test.want)
+		}
+
+		// a path only served for HEAD is still there when HEAD is hidden
+		if rec := r.TestRequest(http.MethodPost, "/head", nil); rec.Code != http.StatusMethodNotAllowed {
+			t.Errorf("order %d, hide HEAD %t, POST /head: got %d, want %d", test.order, test.hideHEAD, rec.Code, http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+func TestRouterCleanPath(t *testing.T) {
+	var gotPath string
+	r := NewMux()
+	r.CleanPath = true
+	r.GET("/api/users", func(w http.ResponseWriter, r *http.Request) error {
+		gotPath = r.URL.Path
+		return nil
+	})
+	r.POST("/api/users/", func(w http.ResponseWriter, r *http.Request) error {
+		gotPath = r.URL.Path
+		return nil
+	})
+
+	tests := []struct {
+		method, path string
+		code         int
+		location     string
+	}{
+		{http.MethodGet, "//api//users", http.StatusMovedPermanently, "/api/users"},
+		{http.MethodGet, "/api/./users/../users?page=2", http.StatusMovedPermanently, "/api/users?page=2"},
+		{http.MethodPost, "/api//users/", http.StatusPermanentRedirect, "/api/users/"},
+		{http.MethodGet, "/api/users", http.StatusOK, ""},
+	}
+
+	for _, test := range tests {
+		rec := r.TestRequest(test.method, test.path, nil)
+		if rec.Code != test.code || rec.Header().Get("Location") != test.location {
+			t.Errorf("%s %s: got %d to %q, want %d to %q", test.method, test.path, rec.Code, rec.Header().Get("Location"), test.code, test.location)
+		}
+	}
+
+	r.RedirectResolvedPath = false
+	for _, test := range tests {
+		gotPath = ""
+		rec := r.TestRequest(test.method, test.path, nil)
+		if want := strings.TrimSuffix(test.location, "?page=2"); rec.Code != http.StatusOK || want != "" && gotPath != want {
+			t.Errorf("%s %s without redirecting: got %d with path %q, want %d with %q", test.method, test.path, rec.Code, gotPath, http.StatusOK, want)
+		}
+	}
+
+	r.CleanPath = false
+	if rec := r.TestRequest(http.MethodGet, "//api//users", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("GET //api//users with CleanPath disabled: got %d, want %d", rec.Code, http.StatusNotFound)
+	}
+}
+
+func TestRouterAsteriskOptions(t *testing.T) {
+	var asterisk int
+	r := NewMux()
+	r.OnAsteriskOptions = func(w http.ResponseWriter, r *http.Request) {
+		asterisk++
+		w.Header().Set("X-Server", "httx")
+		w.WriteHeader(http.StatusNoContent)
+	}
+	r.GET("/path", func(w http.ResponseWriter, r *http.Request) error { return nil })
+	r.POST("/other", func(w http.ResponseWriter, r *http.Request) error { return nil })
+
+	rec := r.TestRequest(http.MethodOptions, "*", nil)
+	if rec.Code != http.StatusNoContent || rec.Header().Get("X-Server") != "httx" || asterisk != 1 {
+		t.Errorf("OPTIONS *: got %d with %d calls, want %d with 1", rec.Code, asterisk, http.StatusNoContent)
+	}
+	if got := strings.Join(rec.Header().Values("Allow"), ", "); got != "GET, OPTIONS, POST" {
+		t.Errorf("OPTIONS *: Allow == %q, want %q", got, "GET, OPTIONS, POST")
+	}
+
+	// path-based OPTIONS don't get the handler
+	rec = r.TestRequest(http.MethodOptions, "/path", nil)
+	if rec.Code != http.StatusOK || asterisk != 1 {
+		t.Errorf("OPTIONS /path: got %d with %d calls, want %d with 1", rec.Code, asterisk, http.StatusOK)
+	}
+
+	r.OnAsteriskOptions = nil
+	rec = r.TestRequest(http.MethodOptions, "*", nil)
+	if rec.Code != http.StatusOK || asterisk != 1 {
+		t.Errorf("OPTIONS * without the handler: got %d with %d calls, want %d with 1", rec.Code, asterisk, http.StatusOK)
+	}
+}
+
+func TestRouterHandleHost(t *testing.T) {
+	r := NewMux()
+	r.HandleHost("acme.example.com", http.MethodGet, "/", func(w http.ResponseWriter, r *http.Request) error {
+		_, err := w.Write([]byte("acme"))
+		return err
+	})
+	r.HandleHost("Globex.example.com:8080", http.MethodGet, "/", func(w http.ResponseWriter, r *http.Request) error {
+		_, err := w.Write([]byte("globex"))
+		return err
+	})
+	r.HandleHost("[::1]", http.MethodGet, "/", func(w http.ResponseWriter, r *http.Request) error {
+		_, err := w.Write([]byte("local"))
+		return err
+	})
+
+	tests := []struct {
+		host string
+		code int
+		body string
+	}{
+		{"acme.example.com", http.StatusOK, "acme"},
+		{"ACME.example.com:443", http.StatusOK, "acme"},
+		{"globex.example.com", http.StatusOK, "globex"},
+		{"[::1]:8080", http.StatusOK, "local"},
+		{"initech.example.com", http.StatusNotFound, ""},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Host = test.host
+
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		if rec.Code != test.code || rec.Body.String() != test.body {
+			t.Errorf("GET / on %s: got %d %q, want %d %q", test.host, rec.Code, rec.Body.String(), test.code, test.body)
+		}
+	}
+
+	if recv := catchPanic(func() {
+		r.HandleHost("acme.example.com:80", http.MethodGet, "/", func(http.ResponseWriter, *http.Request) error { return nil })
+	}); recv == nil {
+		t.Error("registering a duplicate host route did not panic")
+	}
+}
+
+func TestRouterFixedPathRedirectCode(t *testing.T) {
+	noop := func(http.ResponseWriter, *http.Request) error { return nil }
+
+	r := NewMux()
+	r.CleanPath = true
+	r.GET("/users", noop)
+	r.POST("/users", noop)
+	r.GET("/posts/", noop)
+
+	tests := []struct {
+		code         int
+		method, path string
+		want         int
+		location     string
+	}{
+		{0, http.MethodGet, "/USERS", http.StatusMovedPermanently, "/users"},
+		{0, http.MethodPost, "/USERS", http.StatusPermanentRedirect, "/users"},
+		{http.StatusTemporaryRedirect, http.MethodGet, "/USERS", http.StatusTemporaryRedirect, "/users"},
+		{http.StatusTemporaryRedirect, http.MethodPost, "/USERS", http.StatusTemporaryRedirect, "/users"},
+		{http.StatusPermanentRedirect, http.MethodGet, "//users", http.StatusPermanentRedirect, "/users"},
+		{http.StatusPermanentRedirect, http.MethodPost, "//users", http.StatusPermanentRedirect, "/users"},
+		// trailing slash redirects are unaffected
+		{http.StatusTemporaryRedirect, http.MethodGet, "/posts", http.StatusMovedPermanently, "/posts/"},
+	}
+
+	for _, test := range tests {
+		r.FixedPathRedirectCode = test.code
+		rec := r.TestRequest(test.method, test.path, nil)
+		if rec.Code != test.want || rec.Header().Get("Location") != test.location {
+			t.Errorf("code %d, %s %s: got %d to %q, want %d to %q", test.code, test.method, test.path, rec.Code, rec.Header().Get("Location"), test.want, test.location)
+		}
+	}
+}
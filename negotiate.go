@@ -0,0 +1,205 @@
+package httx
+
+import (
+	"mime"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+type acceptSpec struct {
+	value string
+	q     float64
+}
+
+// parseAccept parses Accept-like headers, i.e. comma separated values with
+// optional ";q=" weights. Values with invalid weights are dropped.
+func parseAccept(header string) []acceptSpec {
+	specs := make([]acceptSpec, 0, strings.Count(header, ",")+1)
+
+	for _, part := range strings.Split(header, ",") {
+		value, params, _ := strings.Cut(part, ";")
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+
+		q := 1.0
+		for _, param := range strings.Split(params, ";") {
+			k, v, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if !strings.EqualFold(k, "q") {
+				continue
+			}
+
+			var err error
+			if q, err = strconv.ParseFloat(v, 64); err != nil || q < 0 || q > 1 {
+				q = -1
+			}
+		}
+
+		if q >= 0 {
+			specs = append(specs, acceptSpec{value, q})
+		}
+	}
+
+	return specs
+}
+
+// mediaTypeSpecificity returns how well accepted media range matches offer,
+// -1 meaning no match at all.
+func mediaTypeSpecificity(accepted, offer string) int {
+	switch {
+	case accepted == "*/*":
+		return 0
+	case strings.EqualFold(accepted, offer):
+		return 2
+	case strings.HasSuffix(accepted, "/*"):
+		if i := strings.IndexByte(offer, '/'); i > -1 && strings.EqualFold(accepted[:len(accepted)-1], offer[:i+1]) {
+			return 1
+		}
+	}
+
+	return -1
+}
+
+// Vary adds the headers to the Vary header of the response, skipping the
+// ones already listed there, case-insensitively.
+func Vary(w http.ResponseWriter, headers ...string) {
+	h := w.Header()
+
+	var listed []string
+	for _, value := range h.Values("Vary") {
+		for _, v := range strings.Split(value, ",") {
+			listed = append(listed, strings.TrimSpace(v))
+		}
+	}
+
+	for _, header := range headers {
+		if !slices.ContainsFunc(listed, func(v string) bool { return v == "*" || strings.EqualFold(v, header) }) {
+			h.Add("Vary", header)
+			listed = append(listed, header)
+		}
+	}
+}
+
+// Negotiate returns the best offered media type according to the Accept
+// header of the request, or an empty string if none are acceptable. Accept is
+// added to the Vary header of the response.
+//
+// Weights are respected, ties are resolved in order of offers. A missing
+// Accept header accepts anything, thus the first offer is returned.
+func Negotiate(w http.ResponseWriter, r *http.Request, offers ...string) string {
+	Vary(w, "Accept")
+
+	header := r.Header.Get("Accept")
+	if header == "" {
+		if len(offers) > 0 {
+			return offers[0]
+		}
+		return ""
+	}
+
+	specs := parseAccept(header)
+
+	best, bestQ := "", 0.0
+	for _, offer := range offers {
+		// the most specific matching range decides the weight of an offer
+		q, specificity := 0.0, -1
+		for _, spec := range specs {
+			if s := mediaTypeSpecificity(spec.value, offer); s > specificity {
+				q, specificity = spec.q, s
+			}
+		}
+
+		if q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+
+	return best
+}
+
+// languageSpecificity returns how well the accepted language range matches
+// the offered tag, -1 meaning no match at all. Besides exact matches, a range
+// matches tags it is a prefix of, e.g. "en" matches "en-US", and tags being a
+// prefix of it, so that "en-GB" falls back to "en".
+func languageSpecificity(accepted, offer string) int {
+	hasPrefix := func(tag, prefix string) bool {
+		return len(tag) > len(prefix) && tag[len(prefix)] == '-' && strings.EqualFold(tag[:len(prefix)], prefix)
+	}
+
+	switch {
+	case accepted == "*":
+		return 0
+	case strings.EqualFold(accepted, offer):
+		return 3
+	case hasPrefix(offer, accepted):
+		return 2
+	case hasPrefix(accepted, offer):
+		return 1
+	}
+
+	return -1
+}
+
+// NegotiateLanguage returns the best supported language tag according to the
+// Accept-Language header of the request, or the first supported one if none
+// are acceptable or the header is missing. Accept-Language is added to the
+// Vary header of the response.
+//
+// Weights are respected, ties are resolved in order of supported tags.
+func NegotiateLanguage(w http.ResponseWriter, r *http.Request, supported ...string) string {
+	Vary(w, "Accept-Language")
+
+	if len(supported) == 0 {
+		return ""
+	}
+
+	header := r.Header.Get("Accept-Language")
+	if header == "" {
+		return supported[0]
+	}
+
+	specs := parseAccept(header)
+
+	best, bestQ := supported[0], 0.0
+	for _, offer := range supported {
+		// the most specific matching range decides the weight of a tag
+		q, specificity := 0.0, -1
+		for _, spec := range specs {
+			if s := languageSpecificity(spec.value, offer); s > specificity {
+				q, specificity = spec.q, s
+			}
+		}
+
+		if q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+
+	return best
+}
+
+// RequireContentType returns middleware responding to POST, PUT and PATCH
+// requests with 415 Unsupported Media Type, unless their Content-Type is one
+// of types. Params such as charset are ignored, as is case.
+func RequireContentType(types ...string) func(HandlerFunc) HandlerFunc {
+	allowed := make([]string, len(types))
+	for i, t := range types {
+		allowed[i] = strings.ToLower(t)
+	}
+
+	return func(next HandlerFunc) HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) error {
+			switch r.Method {
+			case http.MethodPost, http.MethodPut, http.MethodPatch:
+				mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+				if err != nil || !slices.Contains(allowed, mediaType) {
+					return &HTTPError{Code: http.StatusUnsupportedMediaType}
+				}
+			}
+			return next(w, r)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package httx
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		accept string
+		offers []string
+		want   string
+	}{
+		{"application/json", []string{"application/xml", "application/json"}, "application/json"},
+		{"application/xml;q=0.5, application/json", []string{"application/xml", "application/json"}, "application/json"},
+		{"text/*;q=0.9, application/json;q=0.8", []string{"application/json", "text/html"}, "text/html"},
+		{"application/json, application/xml", []string{"application/xml", "application/json"}, "application/xml"},
+		{"*/*", []string{"application/json", "application/xml"}, "application/json"},
+		{"*/*;q=0.1, application/json;q=0", []string{"application/json", "text/plain"}, "text/plain"},
+		{"", []string{"application/json"}, "application/json"},
+		{"image/png", []string{"application/json", "application/xml"}, ""},
+		{"application/json;q=0", []string{"application/json"}, ""},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if test.accept != "" {
+			req.Header.Set("Accept", test.accept)
+		}
+
+		rec := httptest.NewRecorder()
+		if got := Negotiate(rec, req, test.offers...); got != test.want {
+			t.Errorf("Negotiate(%q, %v) == %q, want %q", test.accept, test.offers, got, test.want)
+		}
+		if got := rec.Header().Get("Vary"); got != "Accept" {
+			t.Errorf("Negotiate(%q, %v): Vary == %q, want %q", test.accept, test.offers, got, "Accept")
+		}
+	}
+}
+
+func TestNegotiateLanguage(t *testing.T) {
+	tests := []struct {
+		accept    string
+		supported []string
+		want      string
+	}{
+		{"de", []string{"en", "de"}, "de"},
+		{"en-GB", []string{"de", "en"}, "en"},
+		{"en", []string{"de", "en-US"}, "en-US"},
+		{"fr-CH, fr;q=0.9, en;q=0.8", []string{"en", "fr"}, "fr"},
+		{"de;q=0.5, en-US", []string{"de", "en"}, "en"},
+		{"en-us", []string{"de", "en-US"}, "en-US"},
+		{"*", []string{"de", "en"}, "de"},
+		{"ja", []string{"en", "de"}, "en"},
+		{"", []string{"en", "de"}, "en"},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if test.accept != "" {
+			req.Header.Set("Accept-Language", test.accept)
+		}
+
+		rec := httptest.NewRecorder()
+		if got := NegotiateLanguage(rec, req, test.supported...); got != test.want {
+			t.Errorf("NegotiateLanguage(%q, %v) == %q, want %q", test.accept, test.supported, got, test.want)
+		}
+		if got := rec.Header().Get("Vary"); got != "Accept-Language" {
+			t.Errorf("NegotiateLanguage(%q, %v): Vary == %q, want %q", test.accept, test.supported, got, "Accept-Language")
+		}
+	}
+}
+
+func TestVary(t *testing.T) {
+	rec := httptest.NewRecorder()
+	rec.Header().Set("Vary", "Origin, accept")
+
+	Vary(rec, "Accept", "Accept-Encoding")
+	Vary(rec, "Accept-Encoding", "origin", "Accept-Language")
+	Negotiate(rec, httptest.NewRequest(http.MethodGet, "/", nil), "text/plain")
+	NegotiateLanguage(rec, httptest.NewRequest(http.MethodGet, "/", nil), "en")
+
+	want := []string{"Origin, accept", "Accept-Encoding", "Accept-Language"}
+	if got := rec.Header().Values("Vary"); !slices.Equal(got, want) {
+		t.Errorf("Vary == %q, want %q", got, want)
+	}
+
+	rec = httptest.NewRecorder()
+	rec.Header().Set("Vary", "*")
+	Vary(rec, "Accept")
+	if got := rec.Header().Values("Vary"); !slices.Equal(got, []string{"*"}) {
+		t.Errorf("Vary == %q, want %q", got, []string{"*"})
+	}
+}
+
+func TestRequireContentType(t *testing.T) {
+	r := NewMux()
+	r.Pre(RequireContentType("application/json"))
+
+	ok := func(w http.ResponseWriter, r *http.Request) error {
+		return nil
+	}
+	r.GET("/users", ok)
+	r.POST("/users", ok)
+	r.PUT("/users", ok)
+
+	tests := []struct {
+		method, contentType string
+		code                int
+	}{
+		{http.MethodPost, "application/json", http.StatusOK},
+		{http.MethodPost, "Application/JSON; charset=utf-8", http.StatusOK},
+		{http.MethodPut, "text/plain", http.StatusUnsupportedMediaType},
+		{http.MethodPost, "", http.StatusUnsupportedMediaType},
+		{http.MethodGet, "", http.StatusOK},
+		{http.MethodGet, "text/plain", http.StatusOK},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest(test.method, "/users", strings.NewReader("{}"))
+		if test.contentType != "" {
+			req.Header.Set("Content-Type", test.contentType)
+		}
+
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		if rec.Code != test.code {
+			t.Errorf("%s with Content-Type %q: got %d, want %d", test.method, test.contentType, rec.Code, test.code)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package httx
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+)
+
+// Param parses the path value of the given name. A parse error is returned
+// as an HTTPError with 400 Bad Request.
+func Param[T any](r *http.Request, name string, parse func(string) (T, error)) (T, error) {
+	v, err := parse(r.PathValue(name))
+	if err != nil {
+		var zero T
+		return zero, &HTTPError{http.StatusBadRequest, fmt.Errorf("invalid path param %q: %w", name, err)}
+	}
+	return v, nil
+}
+
+// ParamInt is a Param parsing a base 10 int64.
+func ParamInt(r *http.Request, name string) (int64, error) {
+	return Param(r, name, func(s string) (int64, error) {
+		return strconv.ParseInt(s, 10, 64)
+	})
+}
+
+var errInvalidUUID = errors.New("invalid UUID")
+
+// ParamUUID is a Param parsing a UUID in its canonical textual form, e.g.
+// "f47ac10b-58cc-0372-8567-0e02b2c3d479". The result is convertible to UUID
+// types of most libraries.
+func ParamUUID(r *http.Request, name string) ([16]byte, error) {
+	return Param(r, name, parseUUID)
+}
+
+func parseUUID(s string) (uuid [16]byte, err error) {
+	if len(s) != 36 || s[8] != '-' || s[13] != '-' || s[18] != '-' || s[23] != '-' {
+		return uuid, errInvalidUUID
+	}
+
+	src := s[:8] + s[9:13] + s[14:18] + s[19:23] + s[24:]
+	if _, err := hex.Decode(uuid[:], []byte(src)); err != nil {
+		return uuid, errInvalidUUID
+	}
+
+	return uuid, nil
+}
+
+// BindQuery sets the fields of the struct v points to, which are tagged with
+// `query:"name"`, to the query params of the request. Fields may be strings,
+// bools, numbers or slices of them, slices taking every value of the param.
+// Missing params leave their fields untouched, while unparsable ones are
+// returned as an HTTPError with 400 Bad Request.
+func BindQuery(r *http.Request, v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("BindQuery requires a pointer to a struct, got %T", v)
+	}
+	rv = rv.Elem()
+
+	query := r.URL.Query()
+	for i := 0; i < rv.NumField(); i++ {
+		field := rv.Type().Field(i)
+		name := field.Tag.Get("query")
+		if name == "" || name == "-" || !field.IsExported() {
+			continue
+		}
+
+		values, ok := query[name]
+		if !ok {
+			continue
+		}
+
+		if err := setQueryField(rv.Field(i), values); err != nil {
+			return &HTTPError{http.StatusBadRequest, fmt.Errorf("invalid query param %q: %w", name, err)}
+		}
+	}
+
+	return nil
+}
+
+// setQueryField sets the field to the last of the values, or all of them if
+// it's a slice
+func setQueryField(field reflect.Value, values []string) error {
+	if field.Kind() == reflect.Slice {
+		slice := reflect.MakeSlice(field.Type(), len(values), len(values))
+		for i, s := range values {
+			if err := setQueryValue(slice.Index(i), s); err != nil {
+				return err
+			}
+		}
+		field.Set(slice)
+		return nil
+	}
+
+	return setQueryValue(field, values[len(values)-1])
+}
+
+func setQueryValue(v reflect.Value, s string) error {
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	default:
+		return fmt.Errorf("unsupported field type %s", v.Type())
+	}
+
+	return nil
+}
@@ -0,0 +1,117 @@
+package httx
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParamInt(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    int64
+		wantErr bool
+	}{
+		{"42", 42, false},
+		{"-7", -7, false},
+		{"abc", 0, true},
+		{"", 0, true},
+		{"99999999999999999999", 0, true},
+	}
+
+	for _, test := range tests {
+		req, _ := http.NewRequest(http.MethodGet, "/", nil)
+		req.SetPathValue("id", test.value)
+
+		got, err := ParamInt(req, "id")
+		if (err != nil) != test.wantErr || got != test.want {
+			t.Errorf("ParamInt(%q) == %d, %v", test.value, got, err)
+		}
+
+		var httpErr *HTTPError
+		if err != nil && (!errors.As(err, &httpErr) || httpErr.Code != http.StatusBadRequest) {
+			t.Errorf("ParamInt(%q) error == %#v, want HTTPError with 400", test.value, err)
+		}
+	}
+}
+
+func TestParamUUID(t *testing.T) {
+	want := [16]byte{0xf4, 0x7a, 0xc1, 0x0b, 0x58, 0xcc, 0x03, 0x72, 0x85, 0x67, 0x0e, 0x02, 0xb2, 0xc3, 0xd4, 0x79}
+
+	for _, value := range []string{"f47ac10b-58cc-0372-8567-0e02b2c3d479", "F47AC10B-58CC-0372-8567-0E02B2C3D479"} {
+		req, _ := http.NewRequest(http.MethodGet, "/", nil)
+		req.SetPathValue("id", value)
+
+		if got, err := ParamUUID(req, "id"); err != nil || got != want {
+			t.Errorf("ParamUUID(%q) == %x, %v, want %x", value, got, err, want)
+		}
+	}
+
+	for _, value := range []string{"", "f47ac10b58cc03728567-0e02b2c3d479", "f47ac10b-58cc-0372-8567-0e02b2c3d47z", "f47ac10b-58cc-0372-8567-0e02b2c3d4790"} {
+		req, _ := http.NewRequest(http.MethodGet, "/", nil)
+		req.SetPathValue("id", value)
+
+		var httpErr *HTTPError
+		if _, err := ParamUUID(req, "id"); !errors.As(err, &httpErr) || httpErr.Code != http.StatusBadRequest {
+			t.Errorf("ParamUUID(%q) error == %v, want HTTPError with 400", value, err)
+		}
+	}
+}
+
+func TestParamBadRequest(t *testing.T) {
+	r := NewMux()
+	r.GET("/users/{id}", func(w http.ResponseWriter, r *http.Request) error {
+		_, err := ParamInt(r, "id")
+		return err
+	})
+
+	if rec := r.TestRequest(http.MethodGet, "/users/1", nil); rec.Code != http.StatusOK {
+		t.Errorf("status == %d, want %d", rec.Code, http.StatusOK)
+	}
+
+	rec := r.TestRequest(http.MethodGet, "/users/abc", nil)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("status == %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+	if body := rec.Body.String(); !strings.Contains(body, `invalid path param "id"`) {
+		t.Errorf("body == %q", body)
+	}
+}
+
+type listUsers struct {
+	Page    int      `query:"page"`
+	Active  bool     `query:"active"`
+	Tags    []string `query:"tag"`
+	Limit   uint8    `query:"limit"`
+	Sort    string   `query:"sort"`
+	Ignored string
+}
+
+func TestBindQuery(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodGet, "/users?page=3&active=true&tag=a&tag=b&limit=20", nil)
+
+	got := listUsers{Sort: "name", Ignored: "kept"}
+	if err := BindQuery(req, &got); err != nil {
+		t.Fatalf("BindQuery() == %v", err)
+	}
+
+	want := listUsers{Page: 3, Active: true, Tags: []string{"a", "b"}, Limit: 20, Sort: "name", Ignored: "kept"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("BindQuery() set %+v, want %+v", got, want)
+	}
+
+	for _, query := range []string{"page=x", "active=maybe", "limit=300"} {
+		req, _ := http.NewRequest(http.MethodGet, "/users?"+query, nil)
+
+		var httpErr *HTTPError
+		if err := BindQuery(req, &listUsers{}); !errors.As(err, &httpErr) || httpErr.Code != http.StatusBadRequest {
+			t.Errorf("BindQuery(%q) == %v, want a 400 HTTPError", query, err)
+		}
+	}
+
+	if err := BindQuery(req, listUsers{}); err == nil {
+		t.Error("BindQuery() with a non-pointer did not fail")
+	}
+}
@@ -11,6 +11,7 @@ const (
 	errWildcardConflict   = "'%s' in new path '%s' conflicts with existing wildcard '%s' in existing prefix '%s'"
 	errWildcardSlash      = "no / before wildcard in path '%s'"
 	errWildcardNotAtEnd   = "wildcard routes are only allowed at the end of the path in path '%s'"
+	errShadowedRoute      = "route '%s' is unreachable, shadowed by '%s'"
 )
 
 type radixError struct {
@@ -1,7 +1,10 @@
 package radix
 
 import (
+	"io"
 	"net/http"
+	"regexp"
+	"slices"
 	"sort"
 	"strings"
 )
@@ -35,6 +38,7 @@ func (n node) clone() *node {
 	cloneNode.path = n.path
 	cloneNode.tsr = n.tsr
 	cloneNode.handler = n.handler
+	cloneNode.hasWildChild = n.hasWildChild
 
 	if len(n.children) > 0 {
 		cloneNode.children = make([]*node, len(n.children))
@@ -58,6 +62,8 @@ func (n node) clone() *node {
 	}
 
 	cloneNode.paramRegex = n.paramRegex
+	cloneNode.paramGroups = n.paramGroups
+	cloneNode.paramSpans = n.paramSpans
 
 	return cloneNode
 }
@@ -68,6 +74,8 @@ func (n *node) split(i int) {
 	cloneChild.path = cloneChild.path[i:]
 	cloneChild.paramKeys = nil
 	cloneChild.paramRegex = nil
+	cloneChild.paramGroups = nil
+	cloneChild.paramSpans = false
 
 	n.path = n.path[:i]
 	n.handler = nil
@@ -76,6 +84,18 @@ func (n *node) split(i int) {
 	n.children = append(n.children[:0], cloneChild)
 }
 
+// findEndIndex returns the index where the match of the param regex ends,
+// or -1 if it doesn't match. Unlike findEndIndexAndValues, it doesn't
+// allocate.
+func (n *node) findEndIndex(path string) int {
+	match := n.paramRegex.FindString(path)
+	if match == "" && !n.paramRegex.MatchString(path) {
+		return -1
+	}
+
+	return len(match)
+}
+
 func (n *node) findEndIndexAndValues(path string) (int, []string) {
 	index := n.paramRegex.FindStringSubmatchIndex(path)
 	if len(index) == 0 || index[0] != 0 {
@@ -87,23 +107,46 @@ func (n *node) findEndIndexAndValues(path string) (int, []string) {
 	index = index[2:]
 	values := make([]string, len(index)/2)
 
-	i := 0
-	for j := range index {
-		if (j+1)%2 != 0 {
-			continue
+	for i := range values {
+		// optional groups not taking part in the match are left empty
+		if start := index[2*i]; start > -1 {
+			values[i] = strings.Clone(path[start:index[2*i+1]])
 		}
+	}
+
+	return end, values
+}
 
-		values[i] = strings.Clone(path[index[j-1]:index[j]])
+// setPathValues sets the values captured by a param node, segment being
+// used as the value of a plain param. Named groups of a regex set values
+// of their own as well.
+func (n *node) setPathValues(req *http.Request, segment string, values []string) {
+	if values == nil {
+		req.SetPathValue(n.paramKeys[0], strings.Clone(segment))
+		return
+	}
 
-		i++
+	for i, key := range n.paramKeys {
+		req.SetPathValue(key, values[n.paramGroups[i]-1])
 	}
 
-	return end, values
+	for i, name := range n.paramRegex.SubexpNames() {
+		if name != "" {
+			req.SetPathValue(name, values[i-1])
+		}
+	}
 }
 
 func (n *node) setHandler(handler http.Handler, fullPath string) (*node, error) {
-	if n.handler != nil || n.tsr {
+	if n.handler != nil {
 		return n, newRadixError(errSetHandler, fullPath)
+	} else if n.tsr {
+		// the path has only been redirecting to its trailing slash
+		// counterpart so far, which keeps its own handler
+		n.tsr = false
+		n.handler = handler
+
+		return n, nil
 	}
 
 	n.handler = handler
@@ -162,7 +205,12 @@ func (n *node) insert(path, fullPath string, handler http.Handler) (*node, error
 
 			child.nType = wp.pType
 			child.paramKeys = wp.keys
-			child.paramRegex = wp.regex
+			if wp.regex != nil {
+				// anchored, as params match from the start of the path
+				child.paramRegex = regexp.MustCompile("^(?:" + wp.regex.String() + ")")
+			}
+			child.paramGroups = wp.groups
+			child.paramSpans = wp.regex != nil && matchesSlash(wp.pattern)
 		case wildcard:
 			if len(path) == end && n.path[len(n.path)-1] != '/' {
 				return nil, newRadixError(errWildcardSlash, fullPath)
@@ -190,6 +238,9 @@ func (n *node) insert(path, fullPath string, handler http.Handler) (*node, error
 				paramKey: wp.keys[0],
 				handler:  handler,
 			}
+			// the path may have only been redirecting to the one without
+			// the trailing slash so far, the wildcard serves it instead
+			n.tsr = false
 
 			return n, nil
 		}
@@ -208,7 +259,7 @@ func (n *node) insert(path, fullPath string, handler http.Handler) (*node, error
 
 	if child.path == "/" {
 		// Add TSR when split a edge and the remain path to insert is "/"
-		n.tsr = true
+		n.tsr = n.handler == nil
 	} else if strings.HasSuffix(child.path, "/") {
 		child.split(len(child.path) - 1)
 		child.tsr = true
@@ -254,7 +305,16 @@ func (n *node) add(path, fullPath string, handler http.Handler) (*node, error) {
 					return child, newRadixError(errSetHandler, fullPath)
 				}
 
-				return nil, child.wildPathConflict(path, fullPath)
+				// The param is registered with a trailing slash as well
+				if path == child.path+"/" {
+					return child.add("/", fullPath, handler)
+				}
+
+				// params with a regex may share the position with other
+				// params, being tried first
+				if wp.regex == nil && child.paramRegex == nil {
+					return nil, child.wildPathConflict(path, fullPath)
+				}
 			}
 
 			if len(path) > i {
@@ -266,7 +326,7 @@ func (n *node) add(path, fullPath string, handler http.Handler) (*node, error) {
 			}
 		}
 
-		if path == "/" {
+		if path == "/" && n.handler == nil {
 			n.tsr = true
 		}
 
@@ -315,24 +375,44 @@ func (n *node) getFromChild(path string, req *http.Request) (http.Handler, bool)
 
 		case param:
 			end := segmentEndIndex(path, false)
-			values := []string{strings.Clone(path[:end])}
 
-			if child.paramRegex != nil {
-				end, values = child.findEndIndexAndValues(path[:end])
-				if end == -1 {
-					continue
+			// a regex matching slashes may take the rest of the path as
+			// long as it doesn't end with one, which is left to the
+			// trailing slash redirect
+			if child.paramSpans && child.handler != nil && len(path) > end && path[len(path)-1] != '/' {
+				if req == nil {
+					if child.findEndIndex(path) == len(path) {
+						return child.handler, false
+					}
+				} else if spanEnd, values := child.findEndIndexAndValues(path); spanEnd == len(path) {
+					child.setPathValues(req, path, values)
+
+					return child.handler, false
 				}
 			}
 
+			// values are only captured by regex params, plain ones take the
+			// whole segment once matched. Without a request to set them on,
+			// only the end of the match is needed.
+			var values []string
+			switch {
+			case child.paramRegex == nil:
+			case req == nil:
+				end = child.findEndIndex(path[:end])
+			default:
+				end, values = child.findEndIndexAndValues(path[:end])
+			}
+			if end == -1 {
+				continue
+			}
+
 			if len(path) > end {
 				h, tsr := child.getFromChild(path[end:], req)
 				if tsr {
 					return nil, tsr
 				} else if h != nil {
 					if req != nil {
-						for i, key := range child.paramKeys {
-							req.SetPathValue(key, values[i])
-						}
+						child.setPathValues(req, path[:end], values)
 					}
 
 					return h, false
@@ -346,9 +426,7 @@ func (n *node) getFromChild(path string, req *http.Request) (http.Handler, bool)
 					// try another child
 					continue
 				case req != nil:
-					for i, key := range child.paramKeys {
-						req.SetPathValue(key, values[i])
-					}
+					child.setPathValues(req, path[:end], values)
 				}
 
 				return child.handler, false
@@ -422,7 +500,7 @@ func (n *node) findFromChild(path string, buf *[]byte) (bool, bool) {
 			end := segmentEndIndex(path, false)
 
 			if child.paramRegex != nil {
-				end, _ = child.findEndIndexAndValues(path[:end])
+				end = child.findEndIndex(path[:end])
 				if end == -1 {
 					continue
 				}
@@ -464,6 +542,172 @@ func (n *node) findFromChild(path string, buf *[]byte) (bool, bool) {
 	return false, false
 }
 
+// routes returns the paths with a handler in the subtree of the node,
+// beginning with the path of the node itself
+func (n *node) routes() []string {
+	var routes []string
+
+	if n.handler != nil {
+		routes = append(routes, n.path)
+	}
+
+	if n.wildcard != nil {
+		routes = append(routes, n.path+n.wildcard.path)
+	}
+
+	for _, child := range n.children {
+		for _, route := range child.routes() {
+			routes = append(routes, n.path+route)
+		}
+	}
+
+	return routes
+}
+
+// covers checks if the param node matches every value the other one does
+func (n *node) covers(other *node) bool {
+	if n.paramRegex == nil {
+		return len(n.paramKeys) == 1
+	}
+
+	return other.paramRegex != nil && n.paramRegex.String() == other.paramRegex.String()
+}
+
+var paramNameRegex = regexp.MustCompile(`\{[^{}:]*`)
+
+// withoutParamNames strips the names of the params, so that routes only
+// differing in them compare equal
+func withoutParamNames(path string) string {
+	return paramNameRegex.ReplaceAllString(path, "{")
+}
+
+// validate collects the errors for routes shadowed by params or statics
+// tried before them, prefix being the path leading to the node
+func (n *node) validate(prefix string, errs []error) []error {
+	prefix += n.path
+
+	for j, later := range n.children {
+		if later.nType != param {
+			continue
+		}
+
+		errs = later.validateStatics(prefix, n.children[:j], errs)
+
+		for _, earlier := range n.children[:j] {
+			if earlier.nType != param || !earlier.covers(later) {
+				continue
+			}
+
+			earlierRoutes := earlier.routes()
+			for _, route := range later.routes() {
+				rest := withoutParamNames(route[len(later.path):])
+
+				for _, shadowing := range earlierRoutes {
+					if withoutParamNames(shadowing[len(earlier.path):]) == rest {
+						errs = append(errs, newRadixError(errShadowedRoute, prefix+route, prefix+shadowing))
+						break
+					}
+				}
+			}
+		}
+	}
+
+	for _, child := range n.children {
+		errs = child.validate(prefix, errs)
+	}
+
+	return errs
+}
+
+// validateStatics collects the errors for routes of the param node shadowed
+// by the static siblings, which is the case if every value its regex matches
+// is the path of one of them, followed by the same rest of the route
+func (n *node) validateStatics(prefix string, siblings []*node, errs []error) []error {
+	if n.paramRegex == nil {
+		return errs
+	}
+
+	values, ok := regexValues(n.paramRegex.String())
+	if !ok {
+		return errs
+	}
+
+	var statics []string
+	for _, sibling := range siblings {
+		if sibling.nType == static {
+			statics = append(statics, sibling.routes()...)
+		}
+	}
+
+	for _, route := range n.routes() {
+		rest := route[len(n.path):]
+
+		var shadowing string
+		for _, value := range values {
+			i := slices.IndexFunc(statics, func(static string) bool {
+				return withoutParamNames(static) == withoutParamNames(value+rest)
+			})
+			if i == -1 {
+				shadowing = ""
+				break
+			} else if shadowing == "" {
+				shadowing = statics[i]
+			}
+		}
+
+		if shadowing != "" {
+			errs = append(errs, newRadixError(errShadowedRoute, prefix+route, prefix+shadowing))
+		}
+	}
+
+	return errs
+}
+
+// stats tallies the node and its subtree into stats, depth being the one of
+// the node itself
+func (n *node) stats(depth int, stats *TreeStats) {
+	stats.Nodes++
+	stats.MaxDepth = max(stats.MaxDepth, depth)
+
+	if n.nType == param {
+		stats.Params++
+		if n.paramRegex != nil {
+			stats.RegexParams++
+		}
+	}
+
+	if n.wildcard != nil {
+		stats.Wildcards++
+	}
+
+	for _, child := range n.children {
+		child.stats(depth+1, stats)
+	}
+}
+
+// fprint writes the node and its subtree to w, depth being the one of the
+// node itself
+func (n *node) fprint(w io.Writer, depth int) {
+	indent := strings.Repeat("  ", depth)
+
+	line := indent + n.path
+	if n.handler != nil {
+		line += " [handler]"
+	}
+	if n.tsr {
+		line += " [tsr]"
+	}
+	io.WriteString(w, line+"\n")
+
+	if n.wildcard != nil {
+		io.WriteString(w, indent+"  "+n.wildcard.path+" [handler]\n")
+	}
+
+	for _, child := range n.children {
+		child.fprint(w, depth+1)
+	}
+}
+
 // sort sorts the current node and their children
 func (n *node) sort() {
 	for _, child := range n.children {
@@ -483,13 +727,22 @@ func (n *node) Swap(i, j int) {
 	n.children[i], n.children[j] = n.children[j], n.children[i]
 }
 
-// Less checks if the node 'i' has less priority than the node 'j'
+// Less checks if the node 'i' has less priority than the node 'j'.
+//
+// Static nodes come first, then params with a regex, then plain ones, so
+// that the most specific route wins. Wildcards are always tried last.
 func (n *node) Less(i, j int) bool {
-	if n.children[i].nType < n.children[j].nType {
+	ci, cj := n.children[i], n.children[j]
+
+	if ci.nType < cj.nType {
 		return true
-	} else if n.children[i].nType > n.children[j].nType {
+	} else if ci.nType > cj.nType {
 		return false
 	}
 
-	return len(n.children[i].children) > len(n.children[j].children)
+	if ci.nType == param && (ci.paramRegex != nil) != (cj.paramRegex != nil) {
+		return ci.paramRegex != nil
+	}
+
+	return len(ci.children) > len(cj.children)
 }
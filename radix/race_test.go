@@ -0,0 +1,7 @@
+//go:build race
+
+package radix
+
+// raceEnabled reports whether the tests run with the race detector, under
+// which allocations can't be counted reliably
+const raceEnabled = true
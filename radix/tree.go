@@ -2,6 +2,8 @@ package radix
 
 import (
 	"errors"
+	"io"
+	"maps"
 	"net/http"
 	"strings"
 )
@@ -14,6 +16,16 @@ func New() *Tree {
 	}
 }
 
+// Clone returns a deep copy of the tree, which can be modified without
+// affecting the original.
+func (t *Tree) Clone() *Tree {
+	return &Tree{
+		root:     t.root.clone(),
+		patterns: maps.Clone(t.patterns),
+		Mutable:  t.Mutable,
+	}
+}
+
 // Add adds a node with the given handle to the path.
 //
 // WARNING: Not concurrency-safe!
@@ -24,6 +36,9 @@ func (t *Tree) Add(path string, handler http.Handler) {
 		panic("nil handler")
 	}
 
+	checkParamNames(path)
+	checkEmptySegments(path)
+
 	fullPath := path
 
 	i := longestCommonPrefix(path, t.root.path)
@@ -62,6 +77,99 @@ func (t *Tree) Add(path string, handler http.Handler) {
 	t.root.sort()
 }
 
+// AddRoute adds the handler to the path like Add does, recording the pattern
+// the path was derived from, e.g. by expanding optional params. Routes
+// reports the pattern in place of each of its paths.
+//
+// WARNING: Not concurrency-safe!
+func (t *Tree) AddRoute(path, pattern string, handler http.Handler) {
+	t.Add(path, handler)
+
+	if path == pattern {
+		delete(t.patterns, path)
+		return
+	}
+
+	if t.patterns == nil {
+		t.patterns = make(map[string]string)
+	}
+	t.patterns[path] = pattern
+}
+
+// Routes returns the registered routes in the order they are tried, paths
+// added with AddRoute being reported once as their pattern.
+func (t *Tree) Routes() []string {
+	var routes []string
+
+	seen := make(map[string]bool)
+	for _, route := range t.root.routes() {
+		if pattern, ok := t.patterns[route]; ok {
+			route = pattern
+		}
+		if !seen[route] {
+			seen[route] = true
+			routes = append(routes, route)
+		}
+	}
+
+	return routes
+}
+
+// LongestPrefix returns the longest static route with a handler that the
+// path begins with, ending at a segment boundary, e.g. "/a/b" for "/a/b/c"
+// but not for "/a/bc". Routes with params or wildcards are not considered.
+func (t *Tree) LongestPrefix(path string) (matched string, handler http.Handler, ok bool) {
+	n, consumed := t.root, 0
+
+	for strings.HasPrefix(path[consumed:], n.path) {
+		consumed += len(n.path)
+
+		if n.handler != nil && (consumed == len(path) || path[consumed] == '/' || path[consumed-1] == '/') {
+			matched, handler, ok = path[:consumed], n.handler, true
+		}
+
+		if consumed == len(path) {
+			break
+		}
+
+		var next *node
+		for _, child := range n.children {
+			if child.nType == static && child.path[0] == path[consumed] {
+				next = child
+				break
+			}
+		}
+		if next == nil {
+			break
+		}
+		n = next
+	}
+
+	return
+}
+
+// Stats traverses the whole tree, counting its nodes by kind.
+func (t *Tree) Stats() TreeStats {
+	var stats TreeStats
+	t.root.stats(1, &stats)
+	return stats
+}
+
+// String returns a dump of the tree for debugging, see Fprint.
+func (t *Tree) String() string {
+	var sb strings.Builder
+	t.Fprint(&sb)
+	return sb.String()
+}
+
+// Fprint writes a dump of the tree for debugging to w, one node per line,
+// indented by depth. Nodes with a handler are marked with "[handler]", ones
+// redirecting to the trailing slash variant with "[tsr]". Write errors are
+// ignored.
+func (t *Tree) Fprint(w io.Writer) {
+	t.root.fprint(w, 0)
+}
+
 // Get returns the handle registered with the given path (key). The values of
 // param/wildcard are saved as PathValue.
 //
@@ -111,3 +219,13 @@ func (t *Tree) FindCaseInsensitivePath(path string, fixTrailingSlash bool, caseC
 
 	return true
 }
+
+// Validate reports the routes that can never be matched, because a param
+// at the same position, which matches at least the same values, is always
+// tried first with the rest of the route being the same. Param routes whose
+// regex only matches values of static routes at the same position, which
+// are always tried first, are reported as well, e.g. "/{id:me}" is shadowed
+// by "/me". Static routes are never shadowed by params.
+func (t *Tree) Validate() []error {
+	return t.root.validate("", nil)
+}
@@ -1,11 +1,14 @@
 package radix
 
 import (
+	"bytes"
 	"crypto/rand"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"reflect"
+	"slices"
+	"strings"
 	"testing"
 )
 
@@ -389,3 +392,386 @@ func Benchmark_FindCaseInsensitivePath(b *testing.B) {
 		buf = buf[:0]
 	}
 }
+
+func Test_TreeValidate(t *testing.T) {
+	handler := generateHandler()
+
+	tree := New()
+	tree.Add(`/{id:\d+}/info`, handler)
+	tree.Add(`/{num:\d+}/info`, handler)
+	tree.Add(`/{id:\d+}/details`, handler)
+	tree.Add("/static/info", handler)
+
+	errs := tree.Validate()
+	if len(errs) != 1 {
+		t.Fatalf("Tree.Validate() == %v, want a single error", errs)
+	}
+
+	want := `route '/{num:\d+}/info' is unreachable, shadowed by '/{id:\d+}/info'`
+	if errs[0].Error() != want {
+		t.Errorf("Tree.Validate() == %q, want %q", errs[0], want)
+	}
+
+	tree = New()
+	tree.Add(`/{id:\d+}/info`, handler)
+	tree.Add("/{name}/info", handler)
+
+	if errs := tree.Validate(); len(errs) != 0 {
+		t.Errorf("Tree.Validate() == %v, want no errors", errs)
+	}
+
+	// statics are tried first, thus shadow params only matching their paths
+	tree = New()
+	tree.Add("/users/me", handler)
+	tree.Add("/users/you", handler)
+	tree.Add("/users/me/posts", handler)
+	tree.Add("/users/{who:me|you}", handler)
+	tree.Add("/users/{who:me|them}/posts", handler)
+	tree.Add("/users/{id}", handler)
+	tree.Add(`/files/{name:report}-{ext:txt|csv}`, handler)
+	tree.Add("/files/report-txt", handler)
+	tree.Add("/files/report-csv", handler)
+
+	errs = tree.Validate()
+	var got []string
+	for _, err := range errs {
+		got = append(got, err.Error())
+	}
+
+	wantErrs := []string{
+		"route '/users/{who:me|you}' is unreachable, shadowed by '/users/me'",
+		"route '/files/{name:report}-{ext:txt|csv}' is unreachable, shadowed by '/files/report-txt'",
+	}
+	slices.Sort(got)
+	slices.Sort(wantErrs)
+	if !slices.Equal(got, wantErrs) {
+		t.Errorf("Tree.Validate() == %q, want %q", got, wantErrs)
+	}
+}
+
+func Test_TreeRoutes(t *testing.T) {
+	handler := generateHandler()
+
+	tree := New()
+	tree.AddRoute("/show", "/show/{name?}", handler)
+	tree.AddRoute("/show/{name}", "/show/{name?}", handler)
+	tree.Add("/users", handler)
+	tree.Add("/files/{filepath:*}", handler)
+
+	want := []string{"/show/{name?}", "/users", "/files/{filepath:*}"}
+	if got := tree.Routes(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Tree.Routes() == %v, want %v", got, want)
+	}
+}
+
+func Test_TreeStats(t *testing.T) {
+	handler := generateHandler()
+
+	tree := New()
+	tree.Add("/", handler)
+	tree.Add("/users", handler)
+	tree.Add("/users/{id}", handler)
+	tree.Add(`/users/{id:\d+}/posts`, handler)
+	tree.Add("/files/{filepath:*}", handler)
+
+	want := TreeStats{Nodes: 10, MaxDepth: 6, Params: 2, RegexParams: 1, Wildcards: 1}
+	if got := tree.Stats(); got != want {
+		t.Errorf("Tree.Stats() == %+v, want %+v", got, want)
+	}
+}
+
+func Test_TreeWildcardAfterTSR(t *testing.T) {
+	handler := generateHandler()
+	wildcardHandler := generateHandler()
+
+	// the wildcard must serve the trailing slash variant regardless of the
+	// order of registration
+	for _, paths := range [][]string{{"/files", "/files/{filepath:*}"}, {"/files/{filepath:*}", "/files"}} {
+		tree := New()
+		for _, path := range paths {
+			if strings.Contains(path, "*") {
+				tree.Add(path, wildcardHandler)
+			} else {
+				tree.Add(path, handler)
+			}
+		}
+
+		testHandlerAndParams(t, tree, "/files", handler, false, nil)
+		testHandlerAndParams(t, tree, "/files/", wildcardHandler, false, map[string]any{"filepath": ""})
+		testHandlerAndParams(t, tree, "/files/a.txt", wildcardHandler, false, map[string]any{"filepath": "a.txt"})
+	}
+}
+
+func Test_TreeRegexGroups(t *testing.T) {
+	handler := generateHandler()
+
+	tree := New()
+	tree.Add(`/archive/{date:(?P<year>\d{4})-(?P<month>\d{2})}`, handler)
+	tree.Add(`/files/{name:(\w+)(\.tar)?}.{ext:(gz|bz2)}`, handler)
+
+	testHandlerAndParams(t, tree, "/archive/2024-05", handler, false, map[string]any{
+		"date":  "2024-05",
+		"year":  "2024",
+		"month": "05",
+	})
+	testHandlerAndParams(t, tree, "/files/backup.tar.gz", handler, false, map[string]any{
+		"name": "backup.tar",
+		"ext":  "gz",
+	})
+	testHandlerAndParams(t, tree, "/files/backup.bz2", handler, false, map[string]any{
+		"name": "backup",
+		"ext":  "bz2",
+	})
+}
+
+func Test_TreeSpanningRegex(t *testing.T) {
+	imageHandler := generateHandler()
+	userHandler := generateHandler()
+
+	tree := New()
+	tree.Add(`/img/{path:.*\.(jpg|png)}`, imageHandler)
+	tree.Add(`/users/{id:\d+}`, userHandler)
+
+	testHandlerAndParams(t, tree, "/img/c.png", imageHandler, false, map[string]any{"path": "c.png"})
+	testHandlerAndParams(t, tree, "/img/a/b/c.png", imageHandler, false, map[string]any{"path": "a/b/c.png"})
+	testHandlerAndParams(t, tree, "/users/42", userHandler, false, map[string]any{"id": "42"})
+
+	for _, path := range []string{"/img/a/b/c.gif", "/img/a/b.png/c", "/users/42/43"} {
+		if h, _ := tree.Get(path, nil); h != nil {
+			t.Errorf("Get(%q) matched, want no match", path)
+		}
+	}
+}
+
+func Test_TreeEmptySegments(t *testing.T) {
+	handler := generateHandler()
+	tree := New()
+
+	for _, path := range []string{"/a//b", "//", "/a/b//", "/{a}//b"} {
+		if err := catchPanic(func() { tree.Add(path, handler) }); err == nil {
+			t.Errorf("Add(%q) did not panic", path)
+		}
+	}
+
+	for _, path := range []string{"/a/b/", `/{url:https?://.+}`, `/{re:a\/\/b}`} {
+		if err := catchPanic(func() { tree.Add(path, handler) }); err != nil {
+			t.Errorf("Add(%q) panicked: %v", path, err)
+		}
+	}
+}
+
+func Test_TreeFprint(t *testing.T) {
+	handler := generateHandler()
+
+	tree := New()
+	tree.Add("/users/", handler)
+	tree.Add("/users/{id}", handler)
+	tree.Add("/files/{filepath:*}", handler)
+
+	var buf bytes.Buffer
+	tree.Fprint(&buf)
+
+	if got := tree.String(); buf.String() != got {
+		t.Errorf("Tree.Fprint() wrote %q, Tree.String() == %q", buf.String(), got)
+	}
+
+	want := `/
+  users [tsr]
+    / [handler]
+      {id} [handler]
+        / [tsr]
+  files [tsr]
+    /
+      {filepath:*} [handler]
+`
+	if got := tree.String(); got != want {
+		t.Errorf("Tree.String() == %q, want %q", got, want)
+	}
+}
+
+func Test_TreeLongestPrefix(t *testing.T) {
+	handler := generateHandler()
+
+	tree := New()
+	tree.Add("/a", handler)
+	tree.Add("/a/b", handler)
+	tree.Add("/a/b/{id}", handler)
+	tree.Add("/api/", handler)
+
+	tests := []struct {
+		path, matched string
+		ok            bool
+	}{
+		{"/a/b/c", "/a/b", true},
+		{"/a/b", "/a/b", true},
+		{"/a/bc", "/a", true},
+		{"/a/x/y", "/a", true},
+		{"/api/users", "/api/", true},
+		{"/ab", "", false},
+		{"/x/y", "", false},
+	}
+
+	for _, test := range tests {
+		matched, h, ok := tree.LongestPrefix(test.path)
+		if matched != test.matched || ok != test.ok || ok != (h != nil) {
+			t.Errorf("LongestPrefix(%q) == %q, %v, %v, want %q, %v", test.path, matched, h != nil, ok, test.matched, test.ok)
+		}
+	}
+}
+
+func Test_TreePrecedence(t *testing.T) {
+	routes := []string{
+		"/users/{id}",
+		"/users/me",
+		"/files/{name}",
+		`/files/{id:\d+}`,
+		"/files/{filepath:*}",
+	}
+
+	// static > regex param > plain param > wildcard, regardless of the order
+	// of registration
+	for _, reversed := range []bool{false, true} {
+		tree := New()
+		for i := range routes {
+			route := routes[i]
+			if reversed {
+				route = routes[len(routes)-1-i]
+			}
+			tree.Add(route, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(route))
+			}))
+		}
+
+		tests := []struct {
+			path, route string
+		}{
+			{"/users/me", "/users/me"},
+			{"/users/42", "/users/{id}"},
+			{"/files/42", `/files/{id:\d+}`},
+			{"/files/report", "/files/{name}"},
+			{"/files/a/b", "/files/{filepath:*}"},
+		}
+
+		for _, test := range tests {
+			req := httptest.NewRequest(http.MethodGet, test.path, nil)
+			h, _ := tree.Get(test.path, req)
+			if h == nil {
+				t.Errorf("Get(%q) reversed=%v found no handler, want %q", test.path, reversed, test.route)
+				continue
+			}
+
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+			if got := w.Body.String(); got != test.route {
+				t.Errorf("Get(%q) reversed=%v matched %q, want %q", test.path, reversed, got, test.route)
+			}
+		}
+	}
+}
+
+func Test_TreeDuplicateParamNames(t *testing.T) {
+	handler := generateHandler()
+
+	for _, path := range []string{
+		"/{id}/{id}",
+		`/{id}/posts/{id:\d+}`,
+		"/{id}-{id}",
+		`/{id:[a-z]{2}}.{id}`,
+		`/{id}/{date:(?P<id>\d+)-x}`,
+		`/{date:(?P<year>\d{4})-(?P<year>\d{2})}`,
+		`/{date:(?P<date>\d+)}`,
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Add(%q) did not panic", path)
+				}
+			}()
+			New().Add(path, handler)
+		}()
+	}
+
+	for _, path := range []string{"/{a}/{b}", `/{a:[a-z]{2}}-{b}`, "/{a}/{ab}", `/{a}/{b:(?P<c>\d+)-x}`} {
+		New().Add(path, handler)
+	}
+}
+
+func Test_TreeGetStaticAllocs(t *testing.T) {
+	if raceEnabled {
+		t.Skip("the race detector makes regexp allocate")
+	}
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	tree := New()
+	tree.Add("/", handler)
+	tree.Add("/plaintext", handler)
+	tree.Add("/json", handler)
+	tree.Add("/users/me", handler)
+	tree.Add("/users/{id}", handler)
+	tree.Add(`/users/{id:\d+}/posts`, handler)
+	tree.Add("/{category}/list", handler)
+
+	req := httptest.NewRequest("GET", "/", nil)
+
+	for _, path := range []string{"/", "/plaintext", "/json", "/users/me", "/users/me/"} {
+		if allocs := testing.AllocsPerRun(100, func() { tree.Get(path, req) }); allocs != 0 {
+			t.Errorf("Get(%q) allocs == %v, want 0", path, allocs)
+		}
+	}
+
+	// the regex param, tried first, matches "1" without a handler to return
+	for _, path := range []string{"/users/1", "/users/abc"} {
+		if allocs := testing.AllocsPerRun(100, func() { tree.Get(path, nil) }); allocs != 0 {
+			t.Errorf("Get(%q) without request allocs == %v, want 0", path, allocs)
+		}
+	}
+}
+
+func Benchmark_GetStatic(b *testing.B) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	tree := New()
+	req := httptest.NewRequest("GET", "/", nil)
+
+	tree.Add("/users/me", handler)
+	tree.Add("/users/{id}", handler)
+	tree.Add("/{category}/list", handler)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		tree.Get("/users/me", req)
+	}
+}
+
+func Test_TreeTrailingSlashBoth(t *testing.T) {
+	for _, paths := range [][2]string{
+		{"/foo", "/foo/"},
+		{"/foo/", "/foo"},
+		{"/users/{id}", "/users/{id}/"},
+		{"/users/{id}/", "/users/{id}"},
+		{`/posts/{id:\d+}`, `/posts/{id:\d+}/`},
+	} {
+		tree := New()
+		withoutSlash, withSlash := generateHandler(), generateHandler()
+
+		for _, path := range paths {
+			handler := withoutSlash
+			if strings.HasSuffix(path, "/") {
+				handler = withSlash
+			}
+
+			if err := catchPanic(func() { tree.Add(path, handler) }); err != nil {
+				t.Fatalf("Route '%s' - Unexpected panic: %v", path, err)
+			}
+		}
+
+		reqPath := strings.NewReplacer("{id}", "1", `{id:\d+}`, "1").Replace(paths[0])
+		reqPath = strings.TrimSuffix(reqPath, "/")
+
+		testHandlerAndParams(t, tree, reqPath, withoutSlash, false, nil)
+		testHandlerAndParams(t, tree, reqPath+"/", withSlash, false, nil)
+	}
+}
@@ -32,6 +32,10 @@ type node struct {
 
 	paramKeys  []string
 	paramRegex *regexp.Regexp
+	// indexes of the regex groups capturing each of the paramKeys
+	paramGroups []int
+	// whether the regex may match slashes, thus span several segments
+	paramSpans bool
 }
 
 type wildPath struct {
@@ -43,12 +47,35 @@ type wildPath struct {
 
 	pattern string
 	regex   *regexp.Regexp
+	groups  []int
+}
+
+// TreeStats are the counts of a tree's nodes by kind, see Tree.Stats
+type TreeStats struct {
+	// Nodes is the number of nodes, including the ones only redirecting
+	// to the trailing slash variant, but not wildcards.
+	Nodes int
+
+	// MaxDepth is the number of nodes on the longest path from the root,
+	// the root counting as one.
+	MaxDepth int
+
+	// Params is the number of param nodes, RegexParams the number of them
+	// constrained by a regex.
+	Params      int
+	RegexParams int
+
+	// Wildcards is the number of catch-all params.
+	Wildcards int
 }
 
 // Tree is a routes storage
 type Tree struct {
 	root *node
 
+	// paths added by AddRoute mapped to their patterns
+	patterns map[string]string
+
 	// If enabled, the node handler could be updated
 	Mutable bool
 }
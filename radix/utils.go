@@ -1,10 +1,15 @@
 package radix
 
 import (
+	"encoding/binary"
 	"fmt"
+	"math/bits"
 	"regexp"
+	"regexp/syntax"
+	"slices"
 	"strings"
 	"unicode/utf8"
+	"unsafe"
 )
 
 func panicf(s string, args ...any) {
@@ -18,25 +23,217 @@ func panicf(s string, args ...any) {
 // 	return unicode.ToLower(ra) == unicode.ToLower(rb)
 // }
 
+// compileParamRegex compiles the pattern of a param, panicking with the
+// offending param and path on failure
+func compileParamRegex(pattern, key, fullPath string) *regexp.Regexp {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		panicf("invalid regex for param '%s' in path '%s': %v", key, fullPath, err)
+	}
+
+	return re
+}
+
+// matchesSlash reports whether the regex pattern may match a '/'
+func matchesSlash(pattern string) bool {
+	re, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil {
+		return false
+	}
+
+	var walk func(re *syntax.Regexp) bool
+	walk = func(re *syntax.Regexp) bool {
+		switch re.Op {
+		case syntax.OpAnyChar, syntax.OpAnyCharNotNL:
+			return true
+		case syntax.OpLiteral:
+			return slices.Contains(re.Rune, '/')
+		case syntax.OpCharClass:
+			for i := 0; i < len(re.Rune); i += 2 {
+				if re.Rune[i] <= '/' && '/' <= re.Rune[i+1] {
+					return true
+				}
+			}
+		}
+
+		return slices.ContainsFunc(re.Sub, walk)
+	}
+
+	return walk(re)
+}
+
+// checkParamNames panics if a param name is used more than once in the path,
+// as the latter value would silently overwrite the former. Params sharing a
+// segment, thus matched by a single regex, are checked as well, and so are
+// the named groups of regexes, which set values of their own.
+func checkParamNames(path string) {
+	var names []string
+	add := func(name string) {
+		if slices.Contains(names, name) {
+			panicf("duplicate param name '%s' in path '%s'", name, path)
+		}
+		names = append(names, name)
+	}
+
+	depth, start := 0, 0
+	for i := 0; i < len(path); i++ {
+		switch path[i] {
+		case '{':
+			if depth == 0 {
+				start = i + 1
+			}
+			depth++
+		case '}':
+			if depth--; depth != 0 {
+				continue
+			}
+
+			name, pattern, _ := strings.Cut(path[start:i], ":")
+			add(name)
+			for _, group := range groupNames(pattern) {
+				add(group)
+			}
+		}
+	}
+}
+
+// maxRegexValues caps the number of values regexValues enumerates
+const maxRegexValues = 64
+
+// regexValues returns every value the regex pattern matches, as long as
+// there are a few only, e.g. "me" and "you" for "^(?:me|you)".
+func regexValues(pattern string) ([]string, bool) {
+	re, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil {
+		return nil, false
+	}
+
+	var walk func(re *syntax.Regexp) ([]string, bool)
+	walk = func(re *syntax.Regexp) ([]string, bool) {
+		switch re.Op {
+		case syntax.OpEmptyMatch, syntax.OpBeginText:
+			return []string{""}, true
+		case syntax.OpLiteral:
+			if re.Flags&syntax.FoldCase != 0 {
+				return nil, false
+			}
+			return []string{string(re.Rune)}, true
+		case syntax.OpCapture:
+			return walk(re.Sub[0])
+		case syntax.OpAlternate:
+			var values []string
+			for _, sub := range re.Sub {
+				subValues, ok := walk(sub)
+				if !ok || len(values)+len(subValues) > maxRegexValues {
+					return nil, false
+				}
+				values = append(values, subValues...)
+			}
+			return values, true
+		case syntax.OpConcat:
+			values := []string{""}
+			for _, sub := range re.Sub {
+				subValues, ok := walk(sub)
+				if !ok || len(values)*len(subValues) > maxRegexValues {
+					return nil, false
+				}
+
+				var concat []string
+				for _, value := range values {
+					for _, subValue := range subValues {
+						concat = append(concat, value+subValue)
+					}
+				}
+				values = concat
+			}
+			return values, true
+		}
+
+		return nil, false
+	}
+
+	return walk(re)
+}
+
+// groupNames returns the names of the named groups of the regex pattern.
+// Invalid patterns are left to compileParamRegex to report.
+func groupNames(pattern string) []string {
+	re, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil {
+		return nil
+	}
+
+	var names []string
+	var walk func(re *syntax.Regexp)
+	walk = func(re *syntax.Regexp) {
+		if re.Op == syntax.OpCapture && re.Name != "" {
+			names = append(names, re.Name)
+		}
+		for _, sub := range re.Sub {
+			walk(sub)
+		}
+	}
+	walk(re)
+
+	return names
+}
+
+// checkEmptySegments panics if the path contains an empty segment, i.e. a
+// double slash outside of params, such as "/a//b"
+func checkEmptySegments(path string) {
+	depth := 0
+	for i := 0; i < len(path); i++ {
+		switch path[i] {
+		case '\\':
+			i++
+		case '{':
+			depth++
+		case '}':
+			if depth > 0 {
+				depth--
+			}
+		case '/':
+			if depth == 0 && i+1 < len(path) && path[i+1] == '/' {
+				panicf("path must not contain empty segments in path '%s'", path)
+			}
+		}
+	}
+}
+
 // longestCommonPrefix finds the longest common prefix.
 // This also implies that the common prefix contains no ':' or '*'
 // since the existing key can't contain those chars.
+//
+// The strings are compared 8 bytes at a time, the result being moved back
+// to a rune boundary, so that multibyte runes are never split.
 func longestCommonPrefix(a, b string) int {
+	n := min(len(a), len(b))
 	i := 0
-	max := min(utf8.RuneCountInString(a), utf8.RuneCountInString(b))
 
-	for i < max {
-		ra, sizeA := utf8.DecodeRuneInString(a)
-		rb, sizeB := utf8.DecodeRuneInString(b)
+	if n >= 8 {
+		ab := unsafe.Slice(unsafe.StringData(a), len(a))
+		bb := unsafe.Slice(unsafe.StringData(b), len(b))
 
-		a = a[sizeA:]
-		b = b[sizeB:]
-
-		if ra != rb {
-			return i
+		for ; i+8 <= n; i += 8 {
+			if x := binary.LittleEndian.Uint64(ab[i:]) ^ binary.LittleEndian.Uint64(bb[i:]); x != 0 {
+				i += bits.TrailingZeros64(x) / 8
+				return runeBoundary(a, b, i)
+			}
 		}
+	}
+
+	for i < n && a[i] == b[i] {
+		i++
+	}
+
+	return runeBoundary(a, b, i)
+}
 
-		i += sizeA
+// runeBoundary moves the common prefix length i back to the start of the
+// rune it splits, if any
+func runeBoundary(a, b string, i int) int {
+	for i > 0 && ((i < len(a) && !utf8.RuneStart(a[i])) || (i < len(b) && !utf8.RuneStart(b[i]))) {
+		i--
 	}
 
 	return i
@@ -80,11 +277,12 @@ func findWildPath(path string, fullPath string) *wildPath {
 
 				end := start + end + 2
 				wp := &wildPath{
-					path:  path[start:end],
-					keys:  []string{path[start+1 : end-1]},
-					start: start,
-					end:   end,
-					pType: param,
+					path:   path[start:end],
+					keys:   []string{path[start+1 : end-1]},
+					start:  start,
+					end:    end,
+					pType:  param,
+					groups: []int{1},
 				}
 
 				if len(path) > end && path[end] == '{' {
@@ -101,7 +299,7 @@ func findWildPath(path string, fullPath string) *wildPath {
 						wp.pType = wildcard
 					} else {
 						wp.pattern = "(" + pattern + ")"
-						wp.regex = regexp.MustCompile(wp.pattern)
+						wp.regex = compileParamRegex(wp.pattern, wp.keys[0], fullPath)
 					}
 				} else if path[len(path)-1] != '/' {
 					wp.pattern = "(.*)"
@@ -126,6 +324,16 @@ func findWildPath(path string, fullPath string) *wildPath {
 					if wp2 != nil {
 						prefix := path[:wp2.start]
 
+						// groups of the later params come after the ones
+						// of the pattern so far, including nested ones
+						offset := 0
+						if wp.pattern != "" {
+							offset = compileParamRegex(wp.pattern, wp.keys[0], fullPath).NumSubexp()
+						}
+						for _, group := range wp2.groups {
+							wp.groups = append(wp.groups, offset+group)
+						}
+
 						wp.end += wp2.end
 						wp.path += prefix + wp2.path
 						wp.pattern += prefix + wp2.pattern
@@ -136,7 +344,7 @@ func findWildPath(path string, fullPath string) *wildPath {
 						wp.end += len(path)
 					}
 
-					wp.regex = regexp.MustCompile(wp.pattern)
+					wp.regex = compileParamRegex(wp.pattern, wp.keys[0], fullPath)
 				}
 
 				return wp
@@ -4,7 +4,9 @@ import (
 	"fmt"
 	"reflect"
 	"regexp"
+	"strings"
 	"testing"
+	"unicode/utf8"
 )
 
 func Test_findWildPath(t *testing.T) {
@@ -188,3 +190,101 @@ func Test_findWildPathConflict(t *testing.T) {
 		}
 	}
 }
+
+func Test_findWildPathInvalidRegex(t *testing.T) {
+	for _, path := range []string{"/api/{id:[}", "/api/{id:[}/data", "/{id:a(}_{name}"} {
+		err := catchPanic(func() {
+			findWildPath(path, path)
+		})
+
+		if err == nil {
+			t.Errorf("Expected panic for path '%s'", path)
+			continue
+		}
+
+		msg := fmt.Sprint(err)
+		if !strings.Contains(msg, "'"+path+"'") || !strings.Contains(msg, "param 'id'") {
+			t.Errorf("Invalid regex error text for path '%s': %s", path, msg)
+		}
+	}
+}
+
+// longestCommonPrefixNaive is the byte by byte reference implementation of
+// longestCommonPrefix
+func longestCommonPrefixNaive(a, b string) int {
+	i := 0
+	for i < len(a) && i < len(b) && a[i] == b[i] {
+		i++
+	}
+
+	for i > 0 && ((i < len(a) && !utf8.RuneStart(a[i])) || (i < len(b) && !utf8.RuneStart(b[i]))) {
+		i--
+	}
+
+	return i
+}
+
+func Test_longestCommonPrefix(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"/", "", 0},
+		{"/api", "/app", 3},
+		{"/api/v1/users", "/api/v1/users/{id}", 13},
+		{"/api/v1/users/{id}", "/api/v1/users/{id}", 18},
+		{"/api/v1/users", "/api/v2/users", 6},
+		{"/static/files/a", "/static/files/b", 14},
+		{"/ü", "/ü", 3},
+		{"/über", "/übel", 5},
+		{"/ü", "/ö", 1},
+		{"/ünïcödé/päth", "/ünïcödé/pöth", 14},
+	}
+
+	for _, test := range tests {
+		if got := longestCommonPrefix(test.a, test.b); got != test.want {
+			t.Errorf("longestCommonPrefix(%q, %q) == %d, want %d", test.a, test.b, got, test.want)
+		}
+	}
+}
+
+func Fuzz_longestCommonPrefix(f *testing.F) {
+	f.Add("/api/v1/users", "/api/v1/users/{id}")
+	f.Add("/ünïcödé/päth", "/ünïcödé/pöth")
+	f.Add("/a", "/b")
+	f.Add("", "/")
+
+	f.Fuzz(func(t *testing.T, a, b string) {
+		got, want := longestCommonPrefix(a, b), longestCommonPrefixNaive(a, b)
+		if got != want {
+			t.Fatalf("longestCommonPrefix(%q, %q) == %d, want %d", a, b, got, want)
+		}
+
+		if a[:got] != b[:got] {
+			t.Fatalf("longestCommonPrefix(%q, %q) == %d, prefixes differ", a, b, got)
+		}
+	})
+}
+
+var lcpBenchPaths = [][2]string{
+	{"/api/v1/organizations/{org}/projects", "/api/v1/organizations/{org}/members"},
+	{"/static/assets/images/logo.png", "/static/assets/images/icon.svg"},
+	{"/a", "/b"},
+}
+
+func Benchmark_longestCommonPrefix(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		for _, p := range lcpBenchPaths {
+			longestCommonPrefix(p[0], p[1])
+		}
+	}
+}
+
+func Benchmark_longestCommonPrefixNaive(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		for _, p := range lcpBenchPaths {
+			longestCommonPrefixNaive(p[0], p[1])
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package httx
+
+import (
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// RealIP returns the IP of the client, which is the peer of the connection
+// unless it's one of the trusted proxies, given as CIDRs or single IPs.
+// Only then the X-Forwarded-For header is consulted, walking it from the
+// right past any further trusted proxies, with X-Real-IP as a fallback.
+// Invalid entries of trustedProxies are ignored.
+//
+// Headers of untrusted peers are never used, as clients can set them to
+// anything.
+func RealIP(r *http.Request, trustedProxies []string) string {
+	peer := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(peer); err == nil {
+		peer = host
+	}
+
+	var prefixes []netip.Prefix
+	for _, proxy := range trustedProxies {
+		if prefix, err := netip.ParsePrefix(proxy); err == nil {
+			prefixes = append(prefixes, prefix.Masked())
+		} else if addr, err := netip.ParseAddr(proxy); err == nil {
+			prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+		}
+	}
+
+	trusted := func(ip string) bool {
+		addr, err := netip.ParseAddr(ip)
+		if err != nil {
+			return false
+		}
+		addr = addr.Unmap().WithZone("")
+
+		for _, prefix := range prefixes {
+			if prefix.Contains(addr) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if !trusted(peer) {
+		return peer
+	}
+
+	if forwarded := r.Header.Values("X-Forwarded-For"); len(forwarded) > 0 {
+		ips := strings.Split(strings.Join(forwarded, ","), ",")
+
+		for i := len(ips) - 1; i >= 0; i-- {
+			ip := strings.TrimSpace(ips[i])
+			if _, err := netip.ParseAddr(ip); err != nil {
+				// a malformed entry can't be traced any further
+				break
+			}
+			if !trusted(ip) || i == 0 {
+				return ip
+			}
+		}
+	}
+
+	if ip := strings.TrimSpace(r.Header.Get("X-Real-IP")); ip != "" {
+		if _, err := netip.ParseAddr(ip); err == nil {
+			return ip
+		}
+	}
+
+	return peer
+}
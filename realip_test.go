@@ -0,0 +1,48 @@
+package httx
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRealIP(t *testing.T) {
+	trusted := []string{"10.0.0.0/8", "192.168.1.1", "fd00::/8", "invalid"}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		xff        []string
+		xRealIP    string
+		want       string
+	}{
+		{"no proxy", "203.0.113.7:1234", nil, "", "203.0.113.7"},
+		{"trusted proxy", "10.1.2.3:1234", []string{"203.0.113.7"}, "", "203.0.113.7"},
+		{"trusted single IP", "192.168.1.1:1234", []string{"203.0.113.7"}, "", "203.0.113.7"},
+		{"untrusted peer", "198.51.100.1:1234", []string{"203.0.113.7"}, "1.2.3.4", "198.51.100.1"},
+		{"spoofed entry", "10.1.2.3:1234", []string{"1.2.3.4, 203.0.113.7"}, "", "203.0.113.7"},
+		{"proxy chain", "10.1.2.3:1234", []string{"203.0.113.7, 10.9.9.9", "10.8.8.8"}, "", "203.0.113.7"},
+		{"only proxies", "10.1.2.3:1234", []string{"10.9.9.9"}, "", "10.9.9.9"},
+		{"malformed entry", "10.1.2.3:1234", []string{"garbage"}, "", "10.1.2.3"},
+		{"X-Real-IP", "10.1.2.3:1234", nil, "203.0.113.7", "203.0.113.7"},
+		{"IPv6 proxy", "[fd12::1]:1234", []string{"2001:db8::7"}, "", "2001:db8::7"},
+		{"IPv6 untrusted", "[2001:db8::1]:1234", []string{"203.0.113.7"}, "", "2001:db8::1"},
+		{"IPv4-mapped proxy", "[::ffff:10.1.2.3]:1234", []string{"203.0.113.7"}, "", "203.0.113.7"},
+		{"no port", "203.0.113.7", nil, "", "203.0.113.7"},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = test.remoteAddr
+		for _, xff := range test.xff {
+			req.Header.Add("X-Forwarded-For", xff)
+		}
+		if test.xRealIP != "" {
+			req.Header.Set("X-Real-IP", test.xRealIP)
+		}
+
+		if got := RealIP(req, trusted); got != test.want {
+			t.Errorf("%s: RealIP() == %q, want %q", test.name, got, test.want)
+		}
+	}
+}
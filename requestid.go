@@ -0,0 +1,57 @@
+package httx
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+)
+
+// requestIDKey is the key the ID is stored under with Set
+const requestIDKey = "httx.requestID"
+
+// RequestID returns middleware assigning every request an ID, taken from the
+// X-Request-ID header if the client or a proxy sent a sane one, or a random
+// one otherwise. The ID is echoed in the X-Request-ID response header and
+// stored with Set, so that RequestIDFrom finds it even within OnError.
+func RequestID() func(HandlerFunc) HandlerFunc {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) error {
+			id := r.Header.Get("X-Request-ID")
+			if !validRequestID(id) {
+				b := make([]byte, 16)
+				if _, err := rand.Read(b); err != nil {
+					return err
+				}
+				id = hex.EncodeToString(b)
+			}
+
+			w.Header().Set("X-Request-ID", id)
+			Set(w, requestIDKey, id)
+
+			return next(w, r)
+		}
+	}
+}
+
+// RequestIDFrom returns the ID assigned by the RequestID middleware, or an
+// empty string.
+func RequestIDFrom(w http.ResponseWriter) string {
+	id, _ := Get(w, requestIDKey)
+	s, _ := id.(string)
+	return s
+}
+
+// validRequestID checks the ID is short and only made of visible ASCII
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+
+	for _, c := range []byte(id) {
+		if c <= ' ' || c > '~' {
+			return false
+		}
+	}
+
+	return true
+}
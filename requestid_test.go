@@ -0,0 +1,48 @@
+package httx
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequestID(t *testing.T) {
+	r := NewMux()
+	r.Pre(RequestID())
+	r.GET("/", func(w http.ResponseWriter, r *http.Request) error {
+		_, err := w.Write([]byte(RequestIDFrom(w)))
+		return err
+	})
+
+	tests := []struct {
+		header string
+		keep   bool
+	}{
+		{"", false},
+		{"abc-123", true},
+		{"with space", false},
+		{string(make([]byte, 129)), false},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if test.header != "" {
+			req.Header.Set("X-Request-ID", test.header)
+		}
+
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+
+		id := rec.Header().Get("X-Request-ID")
+		if id == "" || rec.Body.String() != id {
+			t.Errorf("X-Request-ID %q: got header %q and body %q, want the same ID", test.header, id, rec.Body.String())
+		}
+		if (id == test.header) != test.keep {
+			t.Errorf("X-Request-ID %q: got ID %q, want it kept: %v", test.header, id, test.keep)
+		}
+	}
+
+	if id := RequestIDFrom(httptest.NewRecorder()); id != "" {
+		t.Errorf("RequestIDFrom() == %q without the middleware, want none", id)
+	}
+}
@@ -0,0 +1,198 @@
+package httx
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+)
+
+// ResponseWriter is the http.ResponseWriter passed by the Mux to handlers,
+// recording the status code and the size of the response written so far.
+//
+// It supports flushing, hijacking, io.ReaderFrom and HTTP/2 push if the
+// original writer does, and unwraps to it for http.ResponseController.
+type ResponseWriter struct {
+	http.ResponseWriter
+	status   int
+	size     int64
+	route    string
+	hijacked bool
+
+	// prepended to the recorded route, the prefix of the mounting mux
+	routePrefix string
+
+	// set as Content-Type if missing once the header is written
+	contentType string
+
+	// whether DefaultErrorHandler may reveal any error message
+	exposeErrors bool
+
+	// the values of Set, created on the first call
+	values map[string]any
+
+	// the innermost Mux serving the request
+	mux *Mux
+
+	// set by Chain, the handler replacing OnNotFound of chainMux
+	chainMux  *Mux
+	chainNext func(http.ResponseWriter, *http.Request)
+}
+
+var responseWriterPool = sync.Pool{
+	New: func() any {
+		return new(ResponseWriter)
+	},
+}
+
+// AsResponseWriter finds the ResponseWriter installed by the Mux, unwrapping
+// w if it's wrapped by middleware.
+func AsResponseWriter(w http.ResponseWriter) (*ResponseWriter, bool) {
+	for {
+		switch t := w.(type) {
+		case *ResponseWriter:
+			return t, true
+		case interface{ Unwrap() http.ResponseWriter }:
+			w = t.Unwrap()
+		default:
+			return nil, false
+		}
+	}
+}
+
+// Status returns the status code written, 0 if none was yet.
+func (rw *ResponseWriter) Status() int {
+	return rw.status
+}
+
+// Size returns the number of body bytes written.
+func (rw *ResponseWriter) Size() int64 {
+	return rw.size
+}
+
+// Route returns the pattern of the matched route, e.g. "/users/{id}", empty
+// if none matched. Middleware registered with Use only sees it once the
+// next handler was called.
+func (rw *ResponseWriter) Route() string {
+	return rw.route
+}
+
+// withRoute makes the handler record the pattern it's registered for in the
+// ResponseWriter. The outermost one wins, so that routes merged from another
+// Mux report the prefixed pattern. Mounted muxes record their own patterns,
+// prefixed with the mount prefix.
+func withRoute(handler HandlerFunc, pattern string) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		if rw, ok := AsResponseWriter(w); ok && rw.route == "" {
+			rw.route = joinRoute(rw.routePrefix, pattern)
+		}
+		return handler(w, r)
+	}
+}
+
+// joinRoute prefixes the pattern, "/" standing for the prefix itself
+func joinRoute(prefix, pattern string) string {
+	if prefix != "" && pattern == "/" {
+		return prefix
+	}
+	return prefix + pattern
+}
+
+// Written reports whether the header has been sent, or the connection was
+// hijacked.
+func (rw *ResponseWriter) Written() bool {
+	return rw.status != 0 || rw.hijacked
+}
+
+func (rw *ResponseWriter) WriteHeader(code int) {
+	// informational responses may precede the final one
+	if rw.status == 0 && (code >= 200 || code == http.StatusSwitchingProtocols) {
+		rw.setContentType(code)
+		rw.status = code
+	}
+	rw.ResponseWriter.WriteHeader(code)
+}
+
+func (rw *ResponseWriter) Write(b []byte) (int, error) {
+	if rw.status == 0 {
+		rw.setContentType(http.StatusOK)
+		rw.status = http.StatusOK
+	}
+	n, err := rw.ResponseWriter.Write(b)
+	rw.size += int64(n)
+	return n, err
+}
+
+func (rw *ResponseWriter) Flush() {
+	_ = rw.FlushError()
+}
+
+// FlushError flushes the underlying writer, as used by http.ResponseController.
+func (rw *ResponseWriter) FlushError() error {
+	if rw.status == 0 {
+		rw.setContentType(http.StatusOK)
+	}
+	err := http.NewResponseController(rw.ResponseWriter).Flush()
+	if err == nil && rw.status == 0 {
+		rw.status = http.StatusOK
+	}
+	return err
+}
+
+// setContentType sets the default Content-Type, see Mux.DefaultContentType,
+// for responses of the code that may have a body, unless it's set already.
+// A nil value, which disables sniffing, counts as set.
+func (rw *ResponseWriter) setContentType(code int) {
+	if rw.contentType == "" || code == http.StatusNoContent || code == http.StatusNotModified || code < 200 {
+		return
+	}
+
+	h := rw.Header()
+	if _, ok := h["Content-Type"]; !ok {
+		h["Content-Type"] = []string{rw.contentType}
+	}
+}
+
+// Hijack takes over the connection, e.g. for websockets, failing with
+// http.ErrNotSupported if the original writer can't be hijacked.
+func (rw *ResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, brw, err := http.NewResponseController(rw.ResponseWriter).Hijack()
+	if err == nil {
+		rw.hijacked = true
+	}
+	return conn, brw, err
+}
+
+// ReadFrom copies src into the response, using the io.ReaderFrom of the
+// original writer if any, so that e.g. files are sent with sendfile.
+func (rw *ResponseWriter) ReadFrom(src io.Reader) (int64, error) {
+	if rw.status == 0 {
+		rw.setContentType(http.StatusOK)
+		rw.status = http.StatusOK
+	}
+
+	var n int64
+	var err error
+	if rf, ok := rw.ResponseWriter.(io.ReaderFrom); ok {
+		n, err = rf.ReadFrom(src)
+	} else {
+		// hides ReadFrom of rw, which io.Copy would call otherwise
+		n, err = io.Copy(struct{ io.Writer }{rw.ResponseWriter}, src)
+	}
+	rw.size += n
+	return n, err
+}
+
+// Push initiates an HTTP/2 server push, failing with http.ErrNotSupported if
+// the original writer doesn't support it.
+func (rw *ResponseWriter) Push(target string, opts *http.PushOptions) error {
+	if pusher, ok := rw.ResponseWriter.(http.Pusher); ok {
+		return pusher.Push(target, opts)
+	}
+	return http.ErrNotSupported
+}
+
+func (rw *ResponseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
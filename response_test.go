@@ -0,0 +1,185 @@
+package httx
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"log"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type wrappedWriter struct {
+	http.ResponseWriter
+}
+
+func (ww wrappedWriter) Unwrap() http.ResponseWriter {
+	return ww.ResponseWriter
+}
+
+func TestResponseWriter(t *testing.T) {
+	var rw *ResponseWriter
+
+	r := NewMux()
+	r.GET("/", func(w http.ResponseWriter, r *http.Request) error {
+		var ok bool
+		if rw, ok = AsResponseWriter(wrappedWriter{w}); !ok {
+			t.Fatal("AsResponseWriter() did not find the writer")
+		}
+
+		if rw.Written() {
+			t.Error("Written() == true before writing")
+		}
+
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte("hello"))
+
+		if rw.Status() != http.StatusAccepted || rw.Size() != 5 || !rw.Written() {
+			t.Errorf("Status() == %d, Size() == %d, want %d, %d", rw.Status(), rw.Size(), http.StatusAccepted, 5)
+		}
+
+		if !Flush(w) {
+			t.Error("Flush() == false")
+		}
+		return nil
+	})
+
+	rec := r.TestRequest(http.MethodGet, "/", nil)
+	if rec.Code != http.StatusAccepted || !rec.Flushed {
+		t.Errorf("status == %d, flushed == %v", rec.Code, rec.Flushed)
+	}
+
+	if _, ok := AsResponseWriter(httptest.NewRecorder()); ok {
+		t.Error("AsResponseWriter() found a writer not installed by Mux")
+	}
+}
+
+func TestRouterPanicAfterWrite(t *testing.T) {
+	r := NewMux()
+	r.GET("/partial", func(w http.ResponseWriter, r *http.Request) error {
+		w.Write([]byte("partial"))
+		panic("oops!")
+	})
+	r.GET("/unwritten", func(w http.ResponseWriter, r *http.Request) error {
+		panic("oops!")
+	})
+
+	defer slog.SetDefault(slog.Default())
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	var serverLog bytes.Buffer
+	s := httptest.NewUnstartedServer(r)
+	s.Config.ErrorLog = log.New(&serverLog, "", 0)
+	s.Start()
+	defer s.Close()
+
+	res, err := http.Get(s.URL + "/partial")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(res.Body)
+	res.Body.Close()
+
+	if res.StatusCode != http.StatusOK || string(body) != "partial" {
+		t.Errorf("GET /partial == %d %q, want %d %q", res.StatusCode, body, http.StatusOK, "partial")
+	}
+
+	res, err = http.Get(s.URL + "/unwritten")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+
+	if res.StatusCode != http.StatusInternalServerError {
+		t.Errorf("GET /unwritten status == %d, want %d", res.StatusCode, http.StatusInternalServerError)
+	}
+
+	if strings.Contains(serverLog.String(), "superfluous") {
+		t.Errorf("unexpected server log: %s", serverLog.String())
+	}
+}
+
+func TestResponseWriterHijack(t *testing.T) {
+	r := NewMux()
+	r.GET("/ws", func(w http.ResponseWriter, r *http.Request) error {
+		hj, ok := w.(http.Hijacker)
+		if !ok {
+			return Abort(http.StatusInternalServerError, "writer is no http.Hijacker")
+		}
+
+		conn, brw, err := hj.Hijack()
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+
+		_, _ = brw.WriteString("HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: echo\r\n\r\n")
+		_ = brw.Flush()
+
+		line, err := brw.ReadString('\n')
+		if err != nil {
+			return err
+		}
+		_, _ = brw.WriteString(line)
+		return brw.Flush()
+	})
+
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	conn, err := net.Dial("tcp", srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	_, _ = io.WriteString(conn, "GET /ws HTTP/1.1\r\nHost: example.com\r\nConnection: Upgrade\r\nUpgrade: echo\r\n\r\n")
+
+	br := bufio.NewReader(conn)
+	res, err := http.ReadResponse(br, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("got %d, want %d", res.StatusCode, http.StatusSwitchingProtocols)
+	}
+
+	_, _ = io.WriteString(conn, "ping\n")
+	if line, err := br.ReadString('\n'); err != nil || line != "ping\n" {
+		t.Errorf("echoed %q with error %v, want %q", line, err, "ping\n")
+	}
+}
+
+func TestResponseWriterReadFrom(t *testing.T) {
+	var status int
+	var size int64
+
+	r := NewMux()
+	r.GET("/", func(w http.ResponseWriter, r *http.Request) error {
+		rf, ok := w.(io.ReaderFrom)
+		if !ok {
+			return Abort(http.StatusInternalServerError, "writer is no io.ReaderFrom")
+		}
+		_, err := rf.ReadFrom(strings.NewReader("hello"))
+
+		rw, _ := AsResponseWriter(w)
+		status, size = rw.Status(), rw.Size()
+		return err
+	})
+
+	rec := r.TestRequest(http.MethodGet, "/", nil)
+	if rec.Code != http.StatusOK || rec.Body.String() != "hello" {
+		t.Errorf("got %d %q, want %d %q", rec.Code, rec.Body.String(), http.StatusOK, "hello")
+	}
+	if status != http.StatusOK || size != 5 {
+		t.Errorf("recorded status %d and size %d, want %d and %d", status, size, http.StatusOK, 5)
+	}
+
+	if err := (&ResponseWriter{ResponseWriter: httptest.NewRecorder()}).Push("/app.js", nil); err != http.ErrNotSupported {
+		t.Errorf("Push() == %v, want %v", err, http.ErrNotSupported)
+	}
+}
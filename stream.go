@@ -0,0 +1,73 @@
+package httx
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+)
+
+// Flush flushes buffered data to the client, reporting whether w, or any
+// writer it unwraps to, supports flushing.
+func Flush(w http.ResponseWriter) bool {
+	return http.NewResponseController(w).Flush() == nil
+}
+
+// Stream calls step until it returns false, flushing after each call, which
+// suits long-polling and chunked responses. It stops with the context error
+// once the request is cancelled, and fails if w can't be flushed.
+func Stream(w http.ResponseWriter, r *http.Request, step func(w io.Writer) bool) error {
+	rc := http.NewResponseController(w)
+	ctx := r.Context()
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		more := step(w)
+		if err := rc.Flush(); err != nil {
+			return err
+		}
+
+		if !more {
+			return nil
+		}
+	}
+}
+
+// JSONEncoder writes newline delimited JSON, see JSONStream.
+type JSONEncoder struct {
+	enc *json.Encoder
+	rc  *http.ResponseController
+	ctx context.Context
+}
+
+// JSONStream starts a newline delimited JSON response, sending its headers
+// with the application/x-ndjson content type right away. It fails if w can't
+// be flushed.
+func JSONStream(w http.ResponseWriter, r *http.Request) (*JSONEncoder, error) {
+	w.Header().Set("Content-Type", "application/x-ndjson")
+
+	rc := http.NewResponseController(w)
+	if err := rc.Flush(); err != nil {
+		w.Header().Del("Content-Type")
+		return nil, err
+	}
+
+	return &JSONEncoder{json.NewEncoder(w), rc, r.Context()}, nil
+}
+
+// Encode writes v as a single line and flushes it. It stops with the context
+// error once the request is cancelled.
+func (e *JSONEncoder) Encode(v any) error {
+	if err := e.ctx.Err(); err != nil {
+		return err
+	}
+
+	if err := e.enc.Encode(v); err != nil {
+		return err
+	}
+
+	return e.rc.Flush()
+}
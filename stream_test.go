@@ -0,0 +1,145 @@
+package httx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// flushRecorder records the body at every flush
+type flushRecorder struct {
+	*httptest.ResponseRecorder
+	flushed []string
+}
+
+func (fr *flushRecorder) Flush() {
+	fr.ResponseRecorder.Flush()
+	fr.flushed = append(fr.flushed, fr.Body.String())
+}
+
+// noFlushWriter hides the Flush method of the recorder
+type noFlushWriter struct {
+	http.ResponseWriter
+}
+
+func TestFlush(t *testing.T) {
+	if !Flush(httptest.NewRecorder()) {
+		t.Error("Flush() == false for a flushable writer")
+	}
+
+	if Flush(noFlushWriter{httptest.NewRecorder()}) {
+		t.Error("Flush() == true for a writer not supporting flushing")
+	}
+}
+
+func TestStream(t *testing.T) {
+	r := NewMux()
+	r.GET("/stream", func(w http.ResponseWriter, r *http.Request) error {
+		i := 0
+		return Stream(w, r, func(w io.Writer) bool {
+			i++
+			fmt.Fprintf(w, "chunk %d\n", i)
+			return i < 3
+		})
+	})
+
+	rec := &flushRecorder{ResponseRecorder: httptest.NewRecorder()}
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/stream", nil))
+
+	want := []string{"chunk 1\n", "chunk 1\nchunk 2\n", "chunk 1\nchunk 2\nchunk 3\n"}
+	if fmt.Sprint(rec.flushed) != fmt.Sprint(want) {
+		t.Errorf("flushed == %q, want %q", rec.flushed, want)
+	}
+}
+
+func TestStreamCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	req := httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx)
+
+	calls := 0
+	err := Stream(httptest.NewRecorder(), req, func(w io.Writer) bool {
+		calls++
+		if calls == 2 {
+			cancel()
+		}
+		return true
+	})
+
+	if !errors.Is(err, context.Canceled) || calls != 2 {
+		t.Errorf("Stream() == %v after %d calls, want %v after 2", err, calls, context.Canceled)
+	}
+
+	err = Stream(noFlushWriter{httptest.NewRecorder()}, httptest.NewRequest(http.MethodGet, "/", nil), func(io.Writer) bool {
+		return true
+	})
+	if !errors.Is(err, http.ErrNotSupported) {
+		t.Errorf("Stream() == %v, want %v", err, http.ErrNotSupported)
+	}
+}
+
+func TestJSONStream(t *testing.T) {
+	r := NewMux()
+	r.GET("/stream", func(w http.ResponseWriter, r *http.Request) error {
+		enc, err := JSONStream(w, r)
+		if err != nil {
+			return err
+		}
+
+		for i := 1; i <= 3; i++ {
+			if err := enc.Encode(map[string]int{"n": i}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	rec := &flushRecorder{ResponseRecorder: httptest.NewRecorder()}
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/stream", nil))
+
+	if got := rec.Header().Get("Content-Type"); got != "application/x-ndjson" {
+		t.Errorf("Content-Type == %q, want %q", got, "application/x-ndjson")
+	}
+
+	want := "{\"n\":1}\n{\"n\":2}\n{\"n\":3}\n"
+	if rec.Code != http.StatusOK || rec.Body.String() != want {
+		t.Errorf("got %d %q, want 200 %q", rec.Code, rec.Body.String(), want)
+	}
+
+	// the headers, then each of the lines
+	if len(rec.flushed) != 4 {
+		t.Errorf("flushed %d times, want 4", len(rec.flushed))
+	}
+}
+
+func TestJSONStreamErrors(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	rec := httptest.NewRecorder()
+
+	enc, err := JSONStream(rec, httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx))
+	if err != nil {
+		t.Fatalf("JSONStream() == %v", err)
+	}
+
+	if err := enc.Encode(1); err != nil {
+		t.Errorf("Encode() == %v", err)
+	}
+	cancel()
+	if err := enc.Encode(2); !errors.Is(err, context.Canceled) {
+		t.Errorf("Encode() == %v after cancelling, want %v", err, context.Canceled)
+	}
+	if rec.Body.String() != "1\n" {
+		t.Errorf("body == %q, want %q", rec.Body.String(), "1\n")
+	}
+
+	rec = httptest.NewRecorder()
+	if _, err := JSONStream(noFlushWriter{rec}, httptest.NewRequest(http.MethodGet, "/", nil)); !errors.Is(err, http.ErrNotSupported) {
+		t.Errorf("JSONStream() == %v, want %v", err, http.ErrNotSupported)
+	}
+	if got := rec.Header().Get("Content-Type"); got != "" {
+		t.Errorf("Content-Type == %q for a failed stream, want none", got)
+	}
+}
@@ -0,0 +1,56 @@
+package httx
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestMuxTestRequest(t *testing.T) {
+	r := NewMux()
+	r.POST("/echo", func(w http.ResponseWriter, r *http.Request) error {
+		_, err := io.Copy(w, r.Body)
+		return err
+	})
+
+	rec := r.TestRequest(http.MethodPost, "/echo", strings.NewReader("hello"))
+	if rec.Code != http.StatusOK || rec.Body.String() != "hello" {
+		t.Errorf("TestRequest() == %d %q, want %d %q", rec.Code, rec.Body.String(), http.StatusOK, "hello")
+	}
+
+	rec = r.TestRequest(http.MethodGet, "/nope", nil)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("TestRequest() status == %d, want %d", rec.Code, http.StatusNotFound)
+	}
+}
+
+func TestMuxTestJSON(t *testing.T) {
+	r := NewMux()
+	r.POST("/users", func(w http.ResponseWriter, r *http.Request) error {
+		if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+			t.Errorf("Content-Type == %q, want %q", ct, "application/json")
+		}
+
+		var in map[string]string
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+			return err
+		}
+		w.WriteHeader(http.StatusCreated)
+		_, err := w.Write([]byte(in["name"]))
+		return err
+	})
+
+	rec, err := r.TestJSON(http.MethodPost, "/users", map[string]string{"name": "gopher"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.Code != http.StatusCreated || rec.Body.String() != "gopher" {
+		t.Errorf("TestJSON() == %d %q, want %d %q", rec.Code, rec.Body.String(), http.StatusCreated, "gopher")
+	}
+
+	if _, err := r.TestJSON(http.MethodPost, "/users", make(chan int)); err == nil {
+		t.Error("TestJSON() with unencodable input did not fail")
+	}
+}
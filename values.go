@@ -0,0 +1,37 @@
+package httx
+
+import (
+	"net/http"
+)
+
+// Set stores a request-scoped value under the key, e.g. for middleware to
+// pass data on to handlers. The values live in a map of the ResponseWriter
+// installed by the Mux, created on the first call, thus requests not setting
+// any don't allocate.
+//
+// The values are visible to everything handling the request afterwards,
+// OnError included, and are gone once the Mux is done serving it. Set does
+// nothing for writers not served by a Mux.
+// Not safe for concurrent use.
+func Set(w http.ResponseWriter, key string, val any) {
+	rw, ok := AsResponseWriter(w)
+	if !ok {
+		return
+	}
+
+	if rw.values == nil {
+		rw.values = make(map[string]any)
+	}
+	rw.values[key] = val
+}
+
+// Get returns the value stored under the key by Set.
+func Get(w http.ResponseWriter, key string) (any, bool) {
+	rw, ok := AsResponseWriter(w)
+	if !ok {
+		return nil, false
+	}
+
+	val, ok := rw.values[key]
+	return val, ok
+}
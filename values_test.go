@@ -0,0 +1,81 @@
+package httx
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestValues(t *testing.T) {
+	r := NewMux()
+	r.Pre(func(next HandlerFunc) HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) error {
+			Set(w, "user", "gopher")
+			Set(w, "admin", true)
+			return next(w, r)
+		}
+	})
+
+	r.GET("/", func(w http.ResponseWriter, r *http.Request) error {
+		if user, ok := Get(w, "user"); !ok || user != "gopher" {
+			t.Errorf("Get(user) == %v, %v, want gopher, true", user, ok)
+		}
+		if admin, ok := Get(w, "admin"); !ok || admin != true {
+			t.Errorf("Get(admin) == %v, %v, want true, true", admin, ok)
+		}
+		if val, ok := Get(w, "missing"); ok {
+			t.Errorf("Get(missing) == %v, true, want nil, false", val)
+		}
+		return nil
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	if _, ok := Get(httptest.NewRecorder(), "user"); ok {
+		t.Error("Get on a writer not served by a Mux reported a value")
+	}
+}
+
+func TestValuesOnError(t *testing.T) {
+	type ctxKey struct{}
+
+	r := NewMux()
+	r.Pre(func(next HandlerFunc) HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) error {
+			r = r.WithContext(context.WithValue(r.Context(), ctxKey{}, true))
+			Set(w, "user", "gopher")
+			return next(w, r)
+		}
+	})
+	r.GET("/", func(w http.ResponseWriter, r *http.Request) error {
+		return errors.New("boom")
+	})
+
+	var user any
+	r.OnError = func(w http.ResponseWriter, r *http.Request, err error) {
+		user, _ = Get(w, "user")
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if user != "gopher" {
+		t.Errorf("Get(user) in OnError == %v, want gopher", user)
+	}
+}
+
+func TestValuesAllocs(t *testing.T) {
+	r := NewMux()
+	r.GET("/", func(w http.ResponseWriter, r *http.Request) error {
+		return nil
+	})
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if allocs := testing.AllocsPerRun(100, func() { r.ServeHTTP(rec, req) }); allocs != 0 {
+		t.Errorf("ServeHTTP allocs == %v without values set, want 0", allocs)
+	}
+}